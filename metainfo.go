@@ -0,0 +1,84 @@
+package cellnet
+
+import (
+	"reflect"
+	"sort"
+)
+
+// 消息字段描述
+type MessageFieldInfo struct {
+	Name string
+	Type string // Go类型, 例如[]int32, *proto.Item
+	Tag  string `json:",omitempty"`
+}
+
+// 消息元信息的导出描述, 用于工具查看运行中的进程注册了哪些消息
+type MessageMetaInfo struct {
+	ID       int
+	FullName string
+	Codec    string
+	GoType   string // 包含完整包路径的类型名
+	Fields   []*MessageFieldInfo
+}
+
+// 按消息元信息生成描述, 只包含导出的字段
+func NewMessageMetaInfo(meta *MessageMeta) *MessageMetaInfo {
+
+	info := &MessageMetaInfo{
+		ID:       meta.ID,
+		FullName: meta.FullName(),
+	}
+
+	if meta.Codec != nil {
+		info.Codec = meta.Codec.Name()
+	}
+
+	if meta.Type == nil {
+		return info
+	}
+
+	info.GoType = meta.Type.PkgPath() + "." + meta.Type.Name()
+
+	if meta.Type.Kind() != reflect.Struct {
+		return info
+	}
+
+	for i := 0; i < meta.Type.NumField(); i++ {
+
+		field := meta.Type.Field(i)
+
+		// 未导出的字段不参与编码
+		if field.PkgPath != "" {
+			continue
+		}
+
+		info.Fields = append(info.Fields, &MessageFieldInfo{
+			Name: field.Name,
+			Type: field.Type.String(),
+			Tag:  string(field.Tag),
+		})
+	}
+
+	return info
+}
+
+// 获取全名符合nameRule正则表达式的消息元信息描述, 为空时返回所有消息, 按消息ID排序
+func MessageMetaInfos(nameRule string) ([]*MessageMetaInfo, error) {
+
+	var list []*MessageMetaInfo
+
+	err := MessageMetaVisit(nameRule, func(meta *MessageMeta) bool {
+		list = append(list, NewMessageMetaInfo(meta))
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	return list, nil
+}
@@ -0,0 +1,70 @@
+package peer
+
+import (
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
+)
+
+// 将消息编码一次, 以*cellnet.RawPacket发送给accessor中满足filter的会话, filter为nil时发送给所有会话
+// 返回发送的会话数量
+func Broadcast(accessor cellnet.SessionAccessor, msg interface{}, filter func(cellnet.Session) bool) (int, error) {
+
+	pkt, err := NewRawPacket(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+
+	accessor.VisitSession(func(ses cellnet.Session) bool {
+
+		if filter == nil || filter(ses) {
+			ses.Send(pkt)
+			count++
+		}
+
+		return true
+	})
+
+	return count, nil
+}
+
+// 将消息发送给给定ID的会话, 消息只编码一次, 返回发送的会话数量
+func BroadcastToIDs(accessor cellnet.SessionAccessor, msg interface{}, idList []int64) (int, error) {
+
+	pkt, err := NewRawPacket(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+
+	for _, id := range idList {
+		if ses := accessor.GetSession(id); ses != nil {
+			ses.Send(pkt)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// 编码消息, 生成可以在多个会话间共享的裸包
+func NewRawPacket(msg interface{}) (*cellnet.RawPacket, error) {
+
+	// 已经编码
+	if pkt, ok := msg.(*cellnet.RawPacket); ok {
+		return pkt, nil
+	}
+
+	// 不使用会话的内存池, 编码结果在多个会话间共享
+	data, meta, err := codec.EncodeMessage(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cellnet.RawPacket{
+		MsgData: data,
+		MsgID:   meta.ID,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package peer
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/util"
+)
+
+// CloseAfterFlush/CloseWithMessage等待发送完成及对方断开的默认时间
+const DefaultCloseFlushTimeout = time.Second * 5
+
+// 根据接收错误判断断开原因及发起方
+func CloseReasonFromError(err error) (cellnet.CloseReason, cellnet.CloseInitiator) {
+
+	switch e := err.(type) {
+	case nil:
+		return cellnet.CloseReason_Manual, cellnet.CloseInitiator_Local
+	case *util.DecodeError:
+		return cellnet.CloseReason_DecodeError, cellnet.CloseInitiator_Local
+	case net.Error:
+		if e.Timeout() {
+			return cellnet.CloseReason_ReadTimeout, cellnet.CloseInitiator_Local
+		}
+	}
+
+	switch err {
+	case util.ErrMaxPacket:
+		return cellnet.CloseReason_MaxPacketExceeded, cellnet.CloseInitiator_Local
+	case io.EOF, io.ErrUnexpectedEOF:
+		return cellnet.CloseReason_IO, cellnet.CloseInitiator_Remote
+	}
+
+	return cellnet.CloseReason_IO, cellnet.CloseInitiator_Unknown
+}
+
+// Peer正在停止时, 关闭的原因为CloseReason_PeerStopping
+func LocalCloseReason(p cellnet.Peer) cellnet.CloseReason {
+
+	if stopper, ok := p.(interface {
+		IsStopping() bool
+	}); ok && stopper.IsStopping() {
+		return cellnet.CloseReason_PeerStopping
+	}
+
+	return cellnet.CloseReason_Manual
+}
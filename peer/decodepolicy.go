@@ -0,0 +1,120 @@
+package peer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/util"
+)
+
+// 消息解码失败或收到未注册的消息时的处理策略
+type DecodeErrorPolicy int
+
+const (
+	DecodeError_Default    DecodeErrorPolicy = iota // 保持原有行为: 解码失败时tcp/ws断开, udp丢弃; 未注册的消息以*cellnet.RawPacket投递
+	DecodeError_Deliver                             // 投递*cellnet.DecodeError事件, 连接保持
+	DecodeError_Drop                                // 丢弃并计数, 连接保持
+	DecodeError_Disconnect                          // 断开连接, 原因为CloseReason_DecodeError; udp没有连接, 只丢弃
+)
+
+// 解码错误处理参数
+type DecodeErrorOption struct {
+	DecodeFailed DecodeErrorPolicy // 消息解码失败, 封包格式错误
+	UnknownID    DecodeErrorPolicy // 消息ID没有注册
+}
+
+type decodeStateKey struct{}
+
+type decodeState struct {
+	opt DecodeErrorOption
+
+	failedCount  uint64
+	unknownCount uint64
+}
+
+var decodeStateGuard sync.Mutex
+
+// 设置Peer的解码错误处理策略, 在Start之前调用
+func SetDecodeErrorPolicy(p cellnet.Peer, opt DecodeErrorOption) {
+	decodeStateOf(p).opt = opt
+}
+
+// Peer上解码失败及收到未注册消息的次数
+func DecodeErrorCount(p cellnet.Peer) (failed, unknown uint64) {
+
+	state := decodeStateOf(p)
+	if state == nil {
+		return 0, 0
+	}
+
+	return atomic.LoadUint64(&state.failedCount), atomic.LoadUint64(&state.unknownCount)
+}
+
+func decodeStateOf(p cellnet.Peer) *decodeState {
+
+	ctxSet, ok := p.(cellnet.ContextSet)
+	if !ok {
+		return nil
+	}
+
+	decodeStateGuard.Lock()
+	defer decodeStateGuard.Unlock()
+
+	if raw, ok := ctxSet.GetContext(decodeStateKey{}); ok {
+		return raw.(*decodeState)
+	}
+
+	state := new(decodeState)
+	ctxSet.SetContext(decodeStateKey{}, state)
+
+	return state
+}
+
+// 接收循环中读取消息后调用, 按Peer的策略处理解码错误及未注册的消息
+// drop为true时丢弃消息继续接收; 返回err时断开连接
+func ResolveDecodeError(ses cellnet.Session, msg interface{}, err error) (outMsg interface{}, drop bool, outErr error) {
+
+	var (
+		decodeErr *util.DecodeError
+		policy    DecodeErrorPolicy
+	)
+
+	switch e := err.(type) {
+	case *util.DecodeError:
+		decodeErr = e
+	case nil:
+		raw, ok := msg.(*cellnet.RawPacket)
+		if !ok {
+			return msg, false, nil
+		}
+
+		decodeErr = &util.DecodeError{MsgID: raw.MsgID, Size: len(raw.MsgData), Err: util.ErrUnknownMsgID}
+	default:
+		return msg, false, err
+	}
+
+	state := decodeStateOf(ses.Peer())
+	if state == nil {
+		return msg, false, err
+	}
+
+	if decodeErr.Err == util.ErrUnknownMsgID {
+		atomic.AddUint64(&state.unknownCount, 1)
+		policy = state.opt.UnknownID
+	} else {
+		atomic.AddUint64(&state.failedCount, 1)
+		policy = state.opt.DecodeFailed
+	}
+
+	switch policy {
+	case DecodeError_Deliver:
+		return &cellnet.DecodeError{MsgID: decodeErr.MsgID, Size: decodeErr.Size, Err: decodeErr.Err}, false, nil
+	case DecodeError_Drop:
+		return nil, true, nil
+	case DecodeError_Disconnect:
+		return nil, false, decodeErr
+	}
+
+	return msg, false, err
+}
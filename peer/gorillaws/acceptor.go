@@ -11,7 +11,7 @@ import (
 )
 
 type wsAcceptor struct {
-	peer.CoreSessionManager
+	peer.SessionManager
 	peer.CorePeerProperty
 	peer.CoreContextSet
 	peer.CoreProcBundle
@@ -29,6 +29,16 @@ type wsAcceptor struct {
 func (self *wsAcceptor) SetUpgrader(upgrader interface{}) {
 	self.upgrader = upgrader.(websocket.Upgrader)
 }
+
+// 设置会话管理器, 实现peer.SessionManager接口, 在Start之前调用
+func (self *wsAcceptor) SetSessionManager(raw interface{}) {
+	self.SessionManager = raw.(peer.SessionManager)
+}
+
+func (self *wsAcceptor) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
+}
+
 func (self *wsAcceptor) Port() int {
 	if self.listener == nil {
 		return 0
@@ -124,6 +134,7 @@ func init() {
 
 	peer.RegisterPeerCreator(func() cellnet.Peer {
 		p := &wsAcceptor{
+			SessionManager: new(peer.CoreSessionManager),
 			upgrader: websocket.Upgrader{
 				CheckOrigin: func(r *http.Request) bool {
 					return true
@@ -14,7 +14,7 @@ import (
 )
 
 type wsConnector struct {
-	peer.CoreSessionManager
+	peer.SessionManager
 
 	peer.CorePeerProperty
 	peer.CoreContextSet
@@ -56,7 +56,11 @@ func (self *wsConnector) Port() int {
 }
 
 func (self *wsConnector) SetSessionManager(raw interface{}) {
-	self.CoreSessionManager = raw.(peer.CoreSessionManager)
+	self.SessionManager = raw.(peer.SessionManager)
+}
+
+func (self *wsConnector) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
 }
 
 func (self *wsConnector) Stop() {
@@ -94,6 +98,12 @@ func (self *wsConnector) connect(address string) {
 	self.SetRunning(true)
 
 	for {
+
+		// 重连等待期间Peer停止时不再连接
+		if self.IsStopping() {
+			break
+		}
+
 		self.tryConnTimes++
 
 		dialer := websocket.Dialer{}
@@ -145,10 +155,22 @@ func (self *wsConnector) connect(address string) {
 
 		}
 
+		// 连接期间Peer停止, 放弃此连接
+		if self.IsStopping() {
+			conn.Close()
+			self.defaultSes.conn = nil
+			break
+		}
+
 		self.sesEndSignal.Add(1)
 
 		self.defaultSes.Start()
 
+		// Start会清除关闭标记, 在Start之前调用的Close失效, 需要重新关闭
+		if self.IsStopping() {
+			self.defaultSes.Close()
+		}
+
 		self.tryConnTimes = 0
 
 		self.ProcEvent(&cellnet.RecvMsgEvent{Ses: self.defaultSes, Msg: &cellnet.SessionConnected{}})
@@ -178,7 +200,9 @@ func (self *wsConnector) TypeName() string {
 func init() {
 
 	peer.RegisterPeerCreator(func() cellnet.Peer {
-		self := &wsConnector{}
+		self := &wsConnector{
+			SessionManager: new(peer.CoreSessionManager),
+		}
 
 		self.defaultSes = newSession(nil, self, func() {
 			self.sesEndSignal.Done()
@@ -2,10 +2,12 @@ package gorillaws
 
 import (
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/peer"
+	"github.com/luis-quan/cellnet/timer"
 	"github.com/luis-quan/cellnet/util"
 )
 
@@ -28,6 +30,14 @@ type wsSession struct {
 	cleanupGuard sync.Mutex
 
 	endNotify func()
+
+	// 本端关闭时指定的原因
+	closeGuard  sync.Mutex
+	closing     bool
+	closeReason cellnet.CloseReason
+	closeCode   int32
+	sendStopped bool
+	flushTimer  timer.AfterStopper
 }
 
 func (self *wsSession) Peer() cellnet.Peer {
@@ -44,7 +54,154 @@ func (self *wsSession) Raw() interface{} {
 }
 
 func (self *wsSession) Close() {
-	self.sendQueue.Add(nil)
+
+	reason := peer.LocalCloseReason(self.Peer())
+
+	// Peer停止时通知对方
+	self.closeWith(reason, 0, reason == cellnet.CloseReason_PeerStopping)
+}
+
+// 发送关闭帧后断开, 对方的SessionClosed中包含原因及关闭码
+func (self *wsSession) CloseWithReason(reason cellnet.CloseReason, code int32) {
+	self.closeWith(reason, code, true)
+}
+
+// 发送完队列中的消息后关闭, 对方断开或超时后释放连接, timeout为0时使用peer.DefaultCloseFlushTimeout
+func (self *wsSession) CloseAfterFlush(timeout time.Duration) {
+	self.flushClose(nil, timeout)
+}
+
+// 发送msg后关闭, 保证msg在断开前发出
+func (self *wsSession) CloseWithMessage(msg interface{}) {
+	self.flushClose(msg, 0)
+}
+
+// 标记关闭, 已经关闭时返回false
+func (self *wsSession) markClosing(reason cellnet.CloseReason, code int32) bool {
+
+	self.closeGuard.Lock()
+	defer self.closeGuard.Unlock()
+
+	if self.closing {
+		return false
+	}
+
+	self.closing = true
+	self.closeReason = reason
+	self.closeCode = code
+
+	return true
+}
+
+// 通知发送循环退出, 只通知一次
+func (self *wsSession) stopSend() {
+
+	self.closeGuard.Lock()
+	defer self.closeGuard.Unlock()
+
+	if !self.sendStopped {
+		self.sendStopped = true
+		self.sendQueue.Add(nil)
+	}
+}
+
+func (self *wsSession) closeWith(reason cellnet.CloseReason, code int32, sendFrame bool) {
+
+	if !self.markClosing(reason, code) {
+		return
+	}
+
+	if sendFrame {
+		self.sendQueue.Add(&cellnet.SessionCloseFrame{Reason: reason, Code: code})
+	}
+
+	self.stopSend()
+}
+
+// 发送循环遇到此标记时发送websocket关闭消息, 对方读完所有数据后断开
+type flushMark struct{}
+
+func (self *wsSession) flushClose(msg interface{}, timeout time.Duration) {
+
+	reason := peer.LocalCloseReason(self.Peer())
+
+	if !self.markClosing(reason, 0) {
+		return
+	}
+
+	conn := self.conn
+	if conn == nil {
+		self.stopSend()
+		return
+	}
+
+	if timeout <= 0 {
+		timeout = peer.DefaultCloseFlushTimeout
+	}
+
+	if msg != nil {
+		self.sendQueue.Add(msg)
+	}
+
+	self.sendQueue.Add(&cellnet.SessionCloseFrame{Reason: reason})
+	self.sendQueue.Add(flushMark{})
+
+	// 发送阻塞或对方不断开时, 强制关闭
+	self.closeGuard.Lock()
+	self.flushTimer = timer.CurrentClock().AfterFunc(timeout, func() {
+		conn.Close()
+	})
+	self.closeGuard.Unlock()
+}
+
+func (self *wsSession) isClosing() bool {
+
+	self.closeGuard.Lock()
+	defer self.closeGuard.Unlock()
+
+	return self.closing
+}
+
+// 本端关闭时使用关闭时指定的原因, 对方发送过关闭帧时使用对方的原因, 否则根据接收错误判断
+func (self *wsSession) makeClosedMsg(err error, frame *cellnet.SessionCloseFrame) *cellnet.SessionClosed {
+
+	self.closeGuard.Lock()
+	defer self.closeGuard.Unlock()
+
+	if self.closing {
+		return &cellnet.SessionClosed{
+			Reason:    self.closeReason,
+			Initiator: cellnet.CloseInitiator_Local,
+			Code:      self.closeCode,
+		}
+	}
+
+	if frame != nil {
+		return &cellnet.SessionClosed{
+			Reason:    frame.Reason,
+			Initiator: cellnet.CloseInitiator_Remote,
+			Code:      frame.Code,
+			Err:       err,
+		}
+	}
+
+	// 对方发送了websocket关闭消息
+	if ce, ok := err.(*websocket.CloseError); ok && ce.Code != websocket.CloseAbnormalClosure {
+		return &cellnet.SessionClosed{
+			Reason:    cellnet.CloseReason_IO,
+			Initiator: cellnet.CloseInitiator_Remote,
+			Code:      int32(ce.Code),
+			Err:       err,
+		}
+	}
+
+	reason, initiator := peer.CloseReasonFromError(err)
+
+	return &cellnet.SessionClosed{
+		Reason:    reason,
+		Initiator: initiator,
+		Err:       err,
+	}
 }
 
 // 发送封包
@@ -55,10 +212,18 @@ func (self *wsSession) Send(msg interface{}) {
 // 接收循环
 func (self *wsSession) recvLoop() {
 
+	// 对方断开前发来的关闭帧
+	var remoteFrame *cellnet.SessionCloseFrame
+
 	for self.conn != nil {
 
 		msg, id, err := self.ReadMessage(self)
 
+		msg, drop, err := peer.ResolveDecodeError(self, msg, err)
+		if drop {
+			continue
+		}
+
 		if err != nil {
 
 			log.Debugln(err)
@@ -67,14 +232,32 @@ func (self *wsSession) recvLoop() {
 				log.Errorln("session closed:", err)
 			}
 
-			self.ProcEvent(&cellnet.RecvMsgEvent{Ses: self, Id: id, Msg: &cellnet.SessionClosed{}})
+			closedMsg := self.makeClosedMsg(err, remoteFrame)
+
+			// 本端发现的错误(封包过大, 解码失败)通知对方
+			if closedMsg.Initiator == cellnet.CloseInitiator_Local && closedMsg.Err != nil {
+				self.closeWith(closedMsg.Reason, 0, true)
+			}
+
+			self.ProcEvent(&cellnet.RecvMsgEvent{Ses: self, Id: id, Msg: closedMsg})
 			break
 		}
 
+		if frame, ok := msg.(*cellnet.SessionCloseFrame); ok {
+			remoteFrame = frame
+			continue
+		}
+
+		// 等待发送完成时, 不再处理收到的消息
+		if self.isClosing() {
+			continue
+		}
+
 		self.ProcEvent(&cellnet.RecvMsgEvent{Ses: self, Id: id, Msg: msg})
 	}
 
-	self.Close()
+	self.markClosing(cellnet.CloseReason_IO, 0)
+	self.stopSend()
 
 	// 通知完成
 	self.exitSync.Done()
@@ -92,6 +275,14 @@ func (self *wsSession) sendLoop() {
 		// 遍历要发送的数据
 		for _, msg := range writeList {
 
+			if _, ok := msg.(flushMark); ok {
+				if self.conn != nil {
+					self.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+				}
+
+				continue
+			}
+
 			// TODO SendMsgEvent并不是很有意义
 			self.SendMessage(&cellnet.SendMsgEvent{Ses: self, Msg: msg})
 		}
@@ -101,6 +292,13 @@ func (self *wsSession) sendLoop() {
 		}
 	}
 
+	self.closeGuard.Lock()
+	if self.flushTimer != nil {
+		self.flushTimer.Stop()
+		self.flushTimer = nil
+	}
+	self.closeGuard.Unlock()
+
 	// 关闭连接
 	if self.conn != nil {
 		self.conn.Close()
@@ -114,6 +312,12 @@ func (self *wsSession) sendLoop() {
 // 启动会话的各种资源
 func (self *wsSession) Start() {
 
+	// connector复用session时, 清除上一次的关闭状态
+	self.closeGuard.Lock()
+	self.closing = false
+	self.sendStopped = false
+	self.closeGuard.Unlock()
+
 	// 将会话添加到管理器
 	self.Peer().(peer.SessionManager).Add(self)
 
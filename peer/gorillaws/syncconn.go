@@ -69,6 +69,10 @@ func (self *wsSyncConnector) SetSessionManager(raw interface{}) {
 	self.SessionManager = raw.(peer.SessionManager)
 }
 
+func (self *wsSyncConnector) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
+}
+
 func (self *wsSyncConnector) ReconnectDuration() time.Duration {
 	return 0
 }
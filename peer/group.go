@@ -0,0 +1,255 @@
+package peer
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/luis-quan/cellnet"
+)
+
+// 会话分组(房间), 会话从管理器移除(SessionClosed)时自动离开所有分组
+// 所有方法可以在任意goroutine调用
+type SessionGroups struct {
+	guard sync.RWMutex
+
+	sesByGroup  map[string]map[int64]cellnet.Session
+	groupsBySes map[int64]map[string]struct{}
+
+	// 会话所在的管理器, 只有仍在管理器中的会话才能加入分组
+	accessor cellnet.SessionAccessor
+}
+
+// 实现了会话分组的会话管理器
+type SessionGroupAccessor interface {
+	Groups() *SessionGroups
+}
+
+// 获取会话分组, accessor可以是Peer或会话管理器(例如resume.Manager), 不支持分组时返回nil
+func GroupsOf(accessor interface{}) *SessionGroups {
+
+	if groupAccessor, ok := accessor.(SessionGroupAccessor); ok {
+		return groupAccessor.Groups()
+	}
+
+	if p, ok := accessor.(cellnet.Peer); ok {
+		if groupAccessor, ok := SessionManagerOf(p).(SessionGroupAccessor); ok {
+			return groupAccessor.Groups()
+		}
+	}
+
+	return nil
+}
+
+// 会话加入分组, 已经在分组中或会话已经关闭时返回false
+func (self *SessionGroups) Join(name string, ses cellnet.Session) bool {
+
+	if ses == nil {
+		return false
+	}
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	// 与管理器移除会话互斥, 避免已经关闭的会话留在分组中
+	if self.accessor != nil && self.accessor.GetSession(ses.ID()) != ses {
+		return false
+	}
+
+	members := self.sesByGroup[name]
+	if members == nil {
+		members = make(map[int64]cellnet.Session)
+		self.sesByGroup[name] = members
+	}
+
+	if _, ok := members[ses.ID()]; ok {
+		return false
+	}
+
+	members[ses.ID()] = ses
+
+	names := self.groupsBySes[ses.ID()]
+	if names == nil {
+		names = make(map[string]struct{})
+		self.groupsBySes[ses.ID()] = names
+	}
+
+	names[name] = struct{}{}
+
+	return true
+}
+
+// 会话离开分组, 不在分组中时返回false, 分组没有成员时自动删除
+func (self *SessionGroups) Leave(name string, ses cellnet.Session) bool {
+
+	if ses == nil {
+		return false
+	}
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	return self.leave(name, ses.ID())
+}
+
+// 会话离开所有分组
+func (self *SessionGroups) LeaveAll(ses cellnet.Session) {
+
+	if ses == nil {
+		return
+	}
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	for name := range self.groupsBySes[ses.ID()] {
+		self.leave(name, ses.ID())
+	}
+}
+
+func (self *SessionGroups) leave(name string, sesID int64) bool {
+
+	members := self.sesByGroup[name]
+	if _, ok := members[sesID]; !ok {
+		return false
+	}
+
+	delete(members, sesID)
+	if len(members) == 0 {
+		delete(self.sesByGroup, name)
+	}
+
+	names := self.groupsBySes[sesID]
+	delete(names, name)
+	if len(names) == 0 {
+		delete(self.groupsBySes, sesID)
+	}
+
+	return true
+}
+
+// 解散分组, 所有成员离开
+func (self *SessionGroups) Dismiss(name string) {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	for sesID := range self.sesByGroup[name] {
+		self.leave(name, sesID)
+	}
+}
+
+// 分组成员的快照, 按会话ID排序
+func (self *SessionGroups) Members(name string) []cellnet.Session {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	members := self.sesByGroup[name]
+
+	list := make([]cellnet.Session, 0, len(members))
+	for _, ses := range members {
+		list = append(list, ses)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID() < list[j].ID()
+	})
+
+	return list
+}
+
+// 分组成员数量
+func (self *SessionGroups) Count(name string) int {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	return len(self.sesByGroup[name])
+}
+
+// 会话是否在分组中
+func (self *SessionGroups) Contains(name string, ses cellnet.Session) bool {
+
+	if ses == nil {
+		return false
+	}
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	_, ok := self.sesByGroup[name][ses.ID()]
+	return ok
+}
+
+// 所有分组名, 按名称排序
+func (self *SessionGroups) Names() []string {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	list := make([]string, 0, len(self.sesByGroup))
+	for name := range self.sesByGroup {
+		list = append(list, name)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
+
+// 会话所在的分组名, 按名称排序
+func (self *SessionGroups) NamesOf(ses cellnet.Session) []string {
+
+	if ses == nil {
+		return nil
+	}
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	names := self.groupsBySes[ses.ID()]
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
+
+// 将消息编码一次, 发送给分组中满足filter的成员, filter为nil时发送给所有成员
+// 返回发送的会话数量
+func (self *SessionGroups) Broadcast(name string, msg interface{}, filter func(cellnet.Session) bool) (int, error) {
+
+	members := self.Members(name)
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	pkt, err := NewRawPacket(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, ses := range members {
+		if filter == nil || filter(ses) {
+			ses.Send(pkt)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// accessor为nil时不检查会话是否仍在管理器中
+func NewSessionGroups(accessor cellnet.SessionAccessor) *SessionGroups {
+
+	return &SessionGroups{
+		sesByGroup:  make(map[string]map[int64]cellnet.Session),
+		groupsBySes: make(map[int64]map[string]struct{}),
+		accessor:    accessor,
+	}
+}
@@ -29,6 +29,8 @@ type httpAcceptor struct {
 	templateExts  []string
 	templateFuncs []template.FuncMap
 
+	metaPath string
+
 	listener net.Listener
 }
 
@@ -111,6 +113,11 @@ func (self *httpAcceptor) Start() cellnet.Peer {
 
 func (self *httpAcceptor) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 
+	if self.metaPath != "" && req.Method == "GET" && req.URL.Path == self.metaPath {
+		self.serveMessageMeta(res, req)
+		return
+	}
+
 	ses := newHttpSession(self, req, res)
 
 	var msg interface{}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/luis-quan/cellnet"
+)
+
+// 消息注册信息的回应
+type messageMetaACK struct {
+	Count    int
+	Messages []*cellnet.MessageMetaInfo
+}
+
+func (self *httpAcceptor) SetMessageMetaServe(path string) {
+
+	self.metaPath = path
+}
+
+func (self *httpAcceptor) serveMessageMeta(res http.ResponseWriter, req *http.Request) {
+
+	infos, err := cellnet.MessageMetaInfos(req.URL.Query().Get("rule"))
+
+	if err != nil {
+
+		log.Warnf("#http.recv(%s) '%s' %s | [%d] %s",
+			self.Name(),
+			req.Method,
+			req.URL.Path,
+			http.StatusBadRequest,
+			err.Error())
+
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := json.Marshal(&messageMetaACK{Count: len(infos), Messages: infos})
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Debugf("#http.recv(%s) '%s' %s | [%d] MessageMeta",
+		self.Name(),
+		req.Method,
+		req.URL.Path,
+		http.StatusOK)
+
+	res.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	res.WriteHeader(http.StatusOK)
+	res.Write(data)
+}
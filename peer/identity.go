@@ -0,0 +1,135 @@
+package peer
+
+import (
+	"errors"
+
+	"github.com/luis-quan/cellnet"
+)
+
+// 同一身份重复登录时的处理策略
+type DuplicatePolicy int
+
+const (
+	Duplicate_KickOld   DuplicatePolicy = iota // 关闭已经绑定的旧会话, 绑定新会话
+	Duplicate_RejectNew                        // 保留旧会话, 绑定失败
+)
+
+var (
+	ErrIdentityInUse = errors.New("identity already bound to another session")
+	ErrNoKeyedAccess = errors.New("peer not support session key")
+)
+
+type identityKey struct{}
+
+// 按用户键访问会话
+type KeyedSessionAccessor interface {
+	SetKey(ses cellnet.Session, key interface{}) error
+	RemoveKey(ses cellnet.Session)
+	SessionByKey(key interface{}) cellnet.Session
+}
+
+// 不由Peer的会话管理器管理的会话实现, 例如可恢复的逻辑会话, 返回管理该会话的用户键访问
+type KeyedSessionOwner interface {
+	KeyedAccessor() KeyedSessionAccessor
+}
+
+// 会话所在的用户键访问, 优先使用会话自己的管理器
+func sessionKeyedAccessorOf(ses cellnet.Session) KeyedSessionAccessor {
+
+	if owner, ok := ses.(KeyedSessionOwner); ok {
+		return owner.KeyedAccessor()
+	}
+
+	return keyedAccessorOf(ses.Peer())
+}
+
+// 获取Peer或会话管理器的用户键访问, 不支持时返回nil
+func keyedAccessorOf(accessor interface{}) KeyedSessionAccessor {
+
+	if keyed, ok := accessor.(KeyedSessionAccessor); ok {
+		return keyed
+	}
+
+	if p, ok := accessor.(cellnet.Peer); ok {
+		if keyed, ok := SessionManagerOf(p).(KeyedSessionAccessor); ok {
+			return keyed
+		}
+	}
+
+	return nil
+}
+
+// 将认证后的身份(例如账号ID)绑定到会话, 同一个Peer上身份唯一, 会话关闭后自动解除绑定
+// 身份已经绑定到其他会话时, 按policy踢掉旧会话(返回被踢的会话)或者返回ErrIdentityInUse
+func Bind(ses cellnet.Session, key interface{}, policy DuplicatePolicy) (kicked cellnet.Session, err error) {
+
+	accessor := sessionKeyedAccessorOf(ses)
+	if accessor == nil {
+		return nil, ErrNoKeyedAccess
+	}
+
+	// 并发踢人时可能被其他会话抢先绑定, 有限次重试
+	for i := 0; i < 3; i++ {
+
+		err = accessor.SetKey(ses, key)
+
+		switch err {
+		case nil:
+			ses.(cellnet.ContextSet).SetContext(identityKey{}, key)
+			return
+		case ErrSessionKeyInUse:
+
+			if policy == Duplicate_RejectNew {
+				return nil, ErrIdentityInUse
+			}
+
+			if old := accessor.SessionByKey(key); old != nil && old != ses {
+				accessor.RemoveKey(old)
+				cellnet.CloseSession(old, cellnet.CloseReason_Kicked, 0)
+				kicked = old
+			}
+
+		default:
+			return
+		}
+	}
+
+	return kicked, ErrIdentityInUse
+}
+
+// 解除会话绑定的身份
+func Unbind(ses cellnet.Session) {
+
+	if accessor := sessionKeyedAccessorOf(ses); accessor != nil {
+		accessor.RemoveKey(ses)
+	}
+
+	ses.(cellnet.ContextSet).SetContext(identityKey{}, nil)
+}
+
+// 会话绑定的身份, 会话关闭后仍然可以获取, 用于处理SessionClosed
+func IdentityOf(ses cellnet.Session) (interface{}, bool) {
+
+	ctxSet, ok := ses.(cellnet.ContextSet)
+	if !ok {
+		return nil, false
+	}
+
+	key, ok := ctxSet.GetContext(identityKey{})
+	if !ok || key == nil {
+		return nil, false
+	}
+
+	return key, true
+}
+
+// 根据身份获取会话, accessor为Peer或会话管理器, 需要支持按用户键访问
+// tcp.ltv.resume的逻辑会话使用resume.ManagerOf(peer)作为accessor
+func SessionByIdentity(accessor cellnet.SessionAccessor, key interface{}) cellnet.Session {
+
+	if keyed := keyedAccessorOf(accessor); keyed != nil {
+		return keyed.SessionByKey(key)
+	}
+
+	return nil
+}
@@ -19,12 +19,36 @@ type SessionManager interface {
 	SetIDBase(base int64)
 }
 
+// 获取Peer使用的会话管理器, 没有时返回nil
+// Peer上只提升了SessionManager接口的方法, 分组(SessionGroupAccessor), 用户键(KeyedSessionAccessor)等可选功能需要对管理器做类型断言
+func SessionManagerOf(p cellnet.Peer) SessionManager {
+
+	if holder, ok := p.(interface {
+		GetSessionManager() SessionManager
+	}); ok {
+		return holder.GetSessionManager()
+	}
+
+	if mgr, ok := p.(SessionManager); ok {
+		return mgr
+	}
+
+	return nil
+}
+
 type CoreSessionManager struct {
 	sesById sync.Map // 使用Id关联会话
 
 	sesIDGen int64 // 记录已经生成的会话ID流水号
 
 	count int64 // 记录当前在使用的会话数量
+
+	groups     *SessionGroups
+	groupsOnce sync.Once
+
+	keyGuard sync.RWMutex
+	sesByKey map[interface{}]cellnet.Session
+	keyByID  map[int64]interface{}
 }
 
 func (self *CoreSessionManager) SetIDBase(base int64) {
@@ -54,6 +78,21 @@ func (self *CoreSessionManager) Remove(ses cellnet.Session) {
 	self.sesById.Delete(ses.ID())
 
 	atomic.AddInt64(&self.count, -1)
+
+	self.RemoveKey(ses)
+
+	// 先从管理器删除, 再离开分组, 保证关闭后的会话无法再加入分组
+	self.Groups().LeaveAll(ses)
+}
+
+// 会话分组, 会话移除时自动离开所有分组
+func (self *CoreSessionManager) Groups() *SessionGroups {
+
+	self.groupsOnce.Do(func() {
+		self.groups = NewSessionGroups(self)
+	})
+
+	return self.groups
 }
 
 // 获得一个连接
@@ -91,3 +130,55 @@ func (self *CoreSessionManager) SessionCount() int {
 
 	return int(v)
 }
+
+func (self *CoreSessionManager) SetKey(ses cellnet.Session, key interface{}) error {
+
+	self.keyGuard.Lock()
+	defer self.keyGuard.Unlock()
+
+	// 与Remove互斥, 已经移除的会话不再建立索引
+	if self.GetSession(ses.ID()) != ses {
+		return ErrSessionNotManaged
+	}
+
+	if exists, ok := self.sesByKey[key]; ok {
+		if exists == ses {
+			return nil
+		}
+
+		return ErrSessionKeyInUse
+	}
+
+	if self.sesByKey == nil {
+		self.sesByKey = make(map[interface{}]cellnet.Session)
+		self.keyByID = make(map[int64]interface{})
+	}
+
+	if oldKey, ok := self.keyByID[ses.ID()]; ok {
+		delete(self.sesByKey, oldKey)
+	}
+
+	self.sesByKey[key] = ses
+	self.keyByID[ses.ID()] = key
+
+	return nil
+}
+
+func (self *CoreSessionManager) RemoveKey(ses cellnet.Session) {
+
+	self.keyGuard.Lock()
+	defer self.keyGuard.Unlock()
+
+	if key, ok := self.keyByID[ses.ID()]; ok {
+		delete(self.sesByKey, key)
+		delete(self.keyByID, ses.ID())
+	}
+}
+
+func (self *CoreSessionManager) SessionByKey(key interface{}) cellnet.Session {
+
+	self.keyGuard.RLock()
+	defer self.keyGuard.RUnlock()
+
+	return self.sesByKey[key]
+}
@@ -0,0 +1,370 @@
+package peer
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/luis-quan/cellnet"
+)
+
+var (
+	ErrSessionKeyInUse   = errors.New("session key already in use")
+	ErrSessionNotManaged = errors.New("session not in manager")
+)
+
+// 默认分片数量
+const DefaultSessionShards = 32
+
+type sessionShard struct {
+	guard   sync.RWMutex
+	sesById map[int64]cellnet.Session
+}
+
+// 会话的附加信息
+type sessionEntry struct {
+	key     interface{}
+	hasKey  bool
+	indexes map[string]interface{}
+}
+
+// 分片存储的会话管理, 适用于会话数量多, 频繁增删的接受器
+// 支持按用户键(例如绑定的账号ID)查找会话, 以及按索引名和值查找一组会话
+// 通过TCPConnector.SetSessionManager或接受器的SetSessionManager在Start之前设置
+type ShardedSessionManager struct {
+	shards []*sessionShard
+
+	sesIDGen int64
+	count    int64
+
+	// 用户键及二级索引
+	indexGuard sync.RWMutex
+	entryByID  map[int64]*sessionEntry
+	sesByKey   map[interface{}]cellnet.Session
+	sesByIndex map[string]map[interface{}]map[int64]cellnet.Session
+
+	groups *SessionGroups
+}
+
+func (self *ShardedSessionManager) shard(id int64) *sessionShard {
+	return self.shards[uint64(id)%uint64(len(self.shards))]
+}
+
+func (self *ShardedSessionManager) SetIDBase(base int64) {
+	atomic.StoreInt64(&self.sesIDGen, base)
+}
+
+func (self *ShardedSessionManager) Count() int {
+	return int(atomic.LoadInt64(&self.count))
+}
+
+// 活跃的会话数量
+func (self *ShardedSessionManager) SessionCount() int {
+	return int(atomic.LoadInt64(&self.count))
+}
+
+func (self *ShardedSessionManager) Add(ses cellnet.Session) {
+
+	id := atomic.AddInt64(&self.sesIDGen, 1)
+
+	ses.(interface {
+		SetID(int64)
+	}).SetID(id)
+
+	shard := self.shard(id)
+	shard.guard.Lock()
+	shard.sesById[id] = ses
+	shard.guard.Unlock()
+
+	atomic.AddInt64(&self.count, 1)
+}
+
+func (self *ShardedSessionManager) Remove(ses cellnet.Session) {
+
+	id := ses.ID()
+
+	shard := self.shard(id)
+	shard.guard.Lock()
+	_, ok := shard.sesById[id]
+	delete(shard.sesById, id)
+	shard.guard.Unlock()
+
+	if !ok {
+		return
+	}
+
+	atomic.AddInt64(&self.count, -1)
+
+	self.indexGuard.Lock()
+	if entry, ok := self.entryByID[id]; ok {
+		self.removeKey(id, entry)
+		for name := range entry.indexes {
+			self.removeIndex(id, entry, name)
+		}
+
+		delete(self.entryByID, id)
+	}
+	self.indexGuard.Unlock()
+
+	// 先从管理器删除, 再离开分组, 保证关闭后的会话无法再加入分组
+	self.groups.LeaveAll(ses)
+}
+
+// 获得一个连接
+func (self *ShardedSessionManager) GetSession(id int64) cellnet.Session {
+
+	shard := self.shard(id)
+	shard.guard.RLock()
+	defer shard.guard.RUnlock()
+
+	return shard.sesById[id]
+}
+
+// 遍历会话快照, 回调中可以安全地关闭会话或增删会话
+func (self *ShardedSessionManager) VisitSession(callback func(cellnet.Session) bool) {
+
+	for _, ses := range self.Snapshot() {
+		if !callback(ses) {
+			break
+		}
+	}
+}
+
+// 所有会话的快照
+func (self *ShardedSessionManager) Snapshot() []cellnet.Session {
+
+	list := make([]cellnet.Session, 0, self.Count())
+
+	for _, shard := range self.shards {
+		shard.guard.RLock()
+		for _, ses := range shard.sesById {
+			list = append(list, ses)
+		}
+		shard.guard.RUnlock()
+	}
+
+	return list
+}
+
+func (self *ShardedSessionManager) CloseAllSession() {
+
+	self.VisitSession(func(ses cellnet.Session) bool {
+
+		ses.Close()
+
+		return true
+	})
+}
+
+// 会话分组, 会话移除时自动离开所有分组
+func (self *ShardedSessionManager) Groups() *SessionGroups {
+	return self.groups
+}
+
+// 为会话设置唯一的用户键, 会话原有的键被替换, 键已经被其他会话使用时返回ErrSessionKeyInUse
+func (self *ShardedSessionManager) SetKey(ses cellnet.Session, key interface{}) error {
+
+	self.indexGuard.Lock()
+	defer self.indexGuard.Unlock()
+
+	// 与Remove互斥, 已经移除的会话不再建立索引
+	if self.GetSession(ses.ID()) != ses {
+		return ErrSessionNotManaged
+	}
+
+	if exists, ok := self.sesByKey[key]; ok {
+		if exists == ses {
+			return nil
+		}
+
+		return ErrSessionKeyInUse
+	}
+
+	entry := self.entry(ses.ID())
+	self.removeKey(ses.ID(), entry)
+
+	entry.key = key
+	entry.hasKey = true
+	self.sesByKey[key] = ses
+
+	return nil
+}
+
+// 移除会话的用户键
+func (self *ShardedSessionManager) RemoveKey(ses cellnet.Session) {
+
+	self.indexGuard.Lock()
+	defer self.indexGuard.Unlock()
+
+	if entry, ok := self.entryByID[ses.ID()]; ok {
+		self.removeKey(ses.ID(), entry)
+	}
+}
+
+// 根据用户键获取会话
+func (self *ShardedSessionManager) SessionByKey(key interface{}) cellnet.Session {
+
+	self.indexGuard.RLock()
+	defer self.indexGuard.RUnlock()
+
+	return self.sesByKey[key]
+}
+
+// 获取会话的用户键
+func (self *ShardedSessionManager) KeyOf(ses cellnet.Session) (interface{}, bool) {
+
+	self.indexGuard.RLock()
+	defer self.indexGuard.RUnlock()
+
+	if entry, ok := self.entryByID[ses.ID()]; ok && entry.hasKey {
+		return entry.key, true
+	}
+
+	return nil, false
+}
+
+// 设置会话在二级索引name上的值(例如所在的服务器, 公会), 每个索引上会话只有一个值, 多个会话可以有相同的值
+func (self *ShardedSessionManager) SetIndex(ses cellnet.Session, name string, value interface{}) error {
+
+	self.indexGuard.Lock()
+	defer self.indexGuard.Unlock()
+
+	if self.GetSession(ses.ID()) != ses {
+		return ErrSessionNotManaged
+	}
+
+	entry := self.entry(ses.ID())
+	self.removeIndex(ses.ID(), entry, name)
+
+	if entry.indexes == nil {
+		entry.indexes = make(map[string]interface{})
+	}
+
+	entry.indexes[name] = value
+
+	byValue := self.sesByIndex[name]
+	if byValue == nil {
+		byValue = make(map[interface{}]map[int64]cellnet.Session)
+		self.sesByIndex[name] = byValue
+	}
+
+	sesByID := byValue[value]
+	if sesByID == nil {
+		sesByID = make(map[int64]cellnet.Session)
+		byValue[value] = sesByID
+	}
+
+	sesByID[ses.ID()] = ses
+
+	return nil
+}
+
+// 移除会话在二级索引name上的值
+func (self *ShardedSessionManager) RemoveIndex(ses cellnet.Session, name string) {
+
+	self.indexGuard.Lock()
+	defer self.indexGuard.Unlock()
+
+	if entry, ok := self.entryByID[ses.ID()]; ok {
+		self.removeIndex(ses.ID(), entry, name)
+	}
+}
+
+// 获取二级索引name上值为value的所有会话
+func (self *ShardedSessionManager) SessionsByIndex(name string, value interface{}) []cellnet.Session {
+
+	self.indexGuard.RLock()
+	defer self.indexGuard.RUnlock()
+
+	sesByID := self.sesByIndex[name][value]
+
+	list := make([]cellnet.Session, 0, len(sesByID))
+	for _, ses := range sesByID {
+		list = append(list, ses)
+	}
+
+	return list
+}
+
+// 二级索引name上值为value的会话数量
+func (self *ShardedSessionManager) IndexCount(name string, value interface{}) int {
+
+	self.indexGuard.RLock()
+	defer self.indexGuard.RUnlock()
+
+	return len(self.sesByIndex[name][value])
+}
+
+// 调用时需要加锁
+func (self *ShardedSessionManager) entry(id int64) *sessionEntry {
+
+	entry := self.entryByID[id]
+	if entry == nil {
+		entry = &sessionEntry{}
+		self.entryByID[id] = entry
+	}
+
+	return entry
+}
+
+// 调用时需要加锁
+func (self *ShardedSessionManager) removeKey(id int64, entry *sessionEntry) {
+
+	if !entry.hasKey {
+		return
+	}
+
+	if exists, ok := self.sesByKey[entry.key]; ok && exists.ID() == id {
+		delete(self.sesByKey, entry.key)
+	}
+
+	entry.key = nil
+	entry.hasKey = false
+}
+
+// 调用时需要加锁
+func (self *ShardedSessionManager) removeIndex(id int64, entry *sessionEntry, name string) {
+
+	value, ok := entry.indexes[name]
+	if !ok {
+		return
+	}
+
+	delete(entry.indexes, name)
+
+	byValue := self.sesByIndex[name]
+	delete(byValue[value], id)
+
+	if len(byValue[value]) == 0 {
+		delete(byValue, value)
+	}
+
+	if len(byValue) == 0 {
+		delete(self.sesByIndex, name)
+	}
+}
+
+// shardCount为0时使用DefaultSessionShards
+func NewShardedSessionManager(shardCount int) *ShardedSessionManager {
+
+	if shardCount <= 0 {
+		shardCount = DefaultSessionShards
+	}
+
+	self := &ShardedSessionManager{
+		shards:     make([]*sessionShard, shardCount),
+		entryByID:  make(map[int64]*sessionEntry),
+		sesByKey:   make(map[interface{}]cellnet.Session),
+		sesByIndex: make(map[string]map[interface{}]map[int64]cellnet.Session),
+	}
+
+	for i := range self.shards {
+		self.shards[i] = &sessionShard{
+			sesById: make(map[int64]cellnet.Session),
+		}
+	}
+
+	self.groups = NewSessionGroups(self)
+
+	return self
+}
@@ -3,6 +3,8 @@ package peer
 import (
 	"net"
 	"time"
+
+	"github.com/luis-quan/cellnet/timer"
 )
 
 type CoreTCPSocketOption struct {
@@ -58,9 +60,7 @@ func (self *CoreTCPSocketOption) ApplySocketReadTimeout(conn net.Conn, callback
 	if self.readTimeout > 0 {
 
 		// issue: http://blog.sina.com.cn/s/blog_9be3b8f10101lhiq.html
-		conn.SetReadDeadline(time.Now().Add(self.readTimeout))
-		callback()
-		conn.SetReadDeadline(time.Time{})
+		applyDeadline(conn.SetReadDeadline, self.readTimeout, callback)
 
 	} else {
 		callback()
@@ -71,15 +71,36 @@ func (self *CoreTCPSocketOption) ApplySocketWriteTimeout(conn net.Conn, callback
 
 	if self.writeTimeout > 0 {
 
-		conn.SetWriteDeadline(time.Now().Add(self.writeTimeout))
-		callback()
-		conn.SetWriteDeadline(time.Time{})
+		applyDeadline(conn.SetWriteDeadline, self.writeTimeout, callback)
 
 	} else {
 		callback()
 	}
 }
 
+// 在超时限制下执行callback
+// 系统时钟时直接使用socket的deadline, 替换了全局时钟时(例如测试用的FakeClock), 由时钟的定时器触发超时
+func applyDeadline(setDeadline func(time.Time) error, timeout time.Duration, callback func()) {
+
+	if timer.IsSystemClock() {
+		setDeadline(time.Now().Add(timeout))
+		callback()
+		setDeadline(time.Time{})
+		return
+	}
+
+	t := timer.CurrentClock().AfterFunc(timeout, func() {
+
+		// 立即超时, 打断阻塞中的读写
+		setDeadline(time.Now())
+	})
+
+	callback()
+
+	t.Stop()
+	setDeadline(time.Time{})
+}
+
 func (self *CoreTCPSocketOption) Init() {
 	self.readBufferSize = -1
 	self.writeBufferSize = -1
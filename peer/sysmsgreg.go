@@ -40,4 +40,14 @@ func init() {
 		Type:  reflect.TypeOf((*cellnet.SessionInit)(nil)).Elem(),
 		ID:    int(util.StringHash("cellnet.SessionInit")),
 	})
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("binary"),
+		Type:  reflect.TypeOf((*cellnet.SessionCloseFrame)(nil)).Elem(),
+		ID:    int(util.StringHash("cellnet.SessionCloseFrame")),
+	})
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("binary"),
+		Type:  reflect.TypeOf((*cellnet.DecodeError)(nil)).Elem(),
+		ID:    int(util.StringHash("cellnet.DecodeError")),
+	})
 }
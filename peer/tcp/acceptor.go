@@ -32,6 +32,15 @@ func (self *tcpAcceptor) Port() int {
 	return self.listener.Addr().(*net.TCPAddr).Port
 }
 
+// 设置会话管理器, 实现peer.SessionManager接口, 在Start之前调用
+func (self *tcpAcceptor) SetSessionManager(raw interface{}) {
+	self.SessionManager = raw.(peer.SessionManager)
+}
+
+func (self *tcpAcceptor) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
+}
+
 func (self *tcpAcceptor) IsReady() bool {
 
 	return self.IsRunning()
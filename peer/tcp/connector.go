@@ -48,6 +48,10 @@ func (self *tcpConnector) SetSessionManager(raw interface{}) {
 	self.SessionManager = raw.(peer.SessionManager)
 }
 
+func (self *tcpConnector) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
+}
+
 func (self *tcpConnector) Stop() {
 	if !self.IsRunning() {
 		return
@@ -95,6 +99,12 @@ func (self *tcpConnector) connect(address string) {
 	self.SetRunning(true)
 
 	for {
+
+		// 重连等待期间Peer停止时不再连接
+		if self.IsStopping() {
+			break
+		}
+
 		self.tryConnTimes++
 
 		// 尝试用Socket连接地址
@@ -130,12 +140,24 @@ func (self *tcpConnector) connect(address string) {
 			continue
 		}
 
+		// 连接期间Peer停止, 放弃此连接
+		if self.IsStopping() {
+			conn.Close()
+			self.defaultSes.setConn(nil)
+			break
+		}
+
 		self.sesEndSignal.Add(1)
 
 		self.ApplySocketOption(conn)
 
 		self.defaultSes.Start()
 
+		// Start会清除关闭标记, 在Start之前调用的Close失效, 需要重新关闭
+		if self.IsStopping() {
+			self.defaultSes.Close()
+		}
+
 		self.tryConnTimes = 0
 
 		self.ProcEvent(&cellnet.RecvMsgEvent{Ses: self.defaultSes, Msg: &cellnet.SessionConnected{}})
@@ -8,6 +8,7 @@ import (
 
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/peer"
+	"github.com/luis-quan/cellnet/timer"
 	"github.com/luis-quan/cellnet/util"
 )
 
@@ -34,6 +35,12 @@ type tcpSession struct {
 	endNotify func()
 
 	closing int64
+
+	// 本端关闭时指定的原因
+	closeGuard  sync.Mutex
+	closeReason cellnet.CloseReason
+	closeCode   int32
+	flushTimer  timer.AfterStopper
 }
 
 func (self *tcpSession) setConn(conn net.Conn) {
@@ -59,14 +66,58 @@ func (self *tcpSession) Raw() interface{} {
 
 func (self *tcpSession) Close() {
 
-	closing := atomic.SwapInt64(&self.closing, 1)
-	if closing != 0 {
+	reason := peer.LocalCloseReason(self.Peer())
+
+	// Peer停止时通知对方
+	self.closeWith(reason, 0, reason == cellnet.CloseReason_PeerStopping)
+}
+
+// 发送关闭帧后断开, 对方的SessionClosed中包含原因及关闭码
+func (self *tcpSession) CloseWithReason(reason cellnet.CloseReason, code int32) {
+	self.closeWith(reason, code, true)
+}
+
+// 发送完队列中的消息后关闭, 对方断开或超时后释放连接, timeout为0时使用peer.DefaultCloseFlushTimeout
+func (self *tcpSession) CloseAfterFlush(timeout time.Duration) {
+	self.flushClose(nil, timeout)
+}
+
+// 发送msg后关闭, 保证msg在断开前发出
+func (self *tcpSession) CloseWithMessage(msg interface{}) {
+	self.flushClose(msg, 0)
+}
+
+// 标记关闭, 已经关闭时返回false
+func (self *tcpSession) markClosing(reason cellnet.CloseReason, code int32) bool {
+
+	self.closeGuard.Lock()
+	defer self.closeGuard.Unlock()
+
+	if atomic.SwapInt64(&self.closing, 1) != 0 {
+		return false
+	}
+
+	self.closeReason = reason
+	self.closeCode = code
+
+	return true
+}
+
+func (self *tcpSession) closeWith(reason cellnet.CloseReason, code int32, sendFrame bool) {
+
+	if !self.markClosing(reason, code) {
 		return
 	}
 
 	conn := self.Conn()
 
 	if conn != nil {
+
+		// 关闭帧在发送循环退出前发出
+		if sendFrame {
+			self.sendQueue.Add(&cellnet.SessionCloseFrame{Reason: reason, Code: code})
+		}
+
 		// 关闭读
 		tcpConn := conn.(*net.TCPConn)
 		// 关闭读
@@ -76,6 +127,41 @@ func (self *tcpSession) Close() {
 	}
 }
 
+// 发送循环遇到此标记时关闭写, 对方读完所有数据后断开
+type flushMark struct{}
+
+func (self *tcpSession) flushClose(msg interface{}, timeout time.Duration) {
+
+	reason := peer.LocalCloseReason(self.Peer())
+
+	if !self.markClosing(reason, 0) {
+		return
+	}
+
+	conn := self.Conn()
+	if conn == nil {
+		return
+	}
+
+	if timeout <= 0 {
+		timeout = peer.DefaultCloseFlushTimeout
+	}
+
+	if msg != nil {
+		self.sendQueue.Add(msg)
+	}
+
+	self.sendQueue.Add(&cellnet.SessionCloseFrame{Reason: reason})
+	self.sendQueue.Add(flushMark{})
+
+	// 发送阻塞或对方不断开时, 强制关闭
+	self.closeGuard.Lock()
+	self.flushTimer = timer.CurrentClock().AfterFunc(timeout, func() {
+		conn.Close()
+	})
+	self.closeGuard.Unlock()
+}
+
 // 发送封包
 func (self *tcpSession) Send(msg interface{}) {
 
@@ -96,6 +182,38 @@ func (self *tcpSession) IsManualClosed() bool {
 	return atomic.LoadInt64(&self.closing) != 0
 }
 
+// 本端关闭时使用关闭时指定的原因, 对方发送过关闭帧时使用对方的原因, 否则根据接收错误判断
+func (self *tcpSession) makeClosedMsg(err error, frame *cellnet.SessionCloseFrame) *cellnet.SessionClosed {
+
+	self.closeGuard.Lock()
+	defer self.closeGuard.Unlock()
+
+	if self.IsManualClosed() {
+		return &cellnet.SessionClosed{
+			Reason:    self.closeReason,
+			Initiator: cellnet.CloseInitiator_Local,
+			Code:      self.closeCode,
+		}
+	}
+
+	if frame != nil {
+		return &cellnet.SessionClosed{
+			Reason:    frame.Reason,
+			Initiator: cellnet.CloseInitiator_Remote,
+			Code:      frame.Code,
+			Err:       err,
+		}
+	}
+
+	reason, initiator := peer.CloseReasonFromError(err)
+
+	return &cellnet.SessionClosed{
+		Reason:    reason,
+		Initiator: initiator,
+		Err:       err,
+	}
+}
+
 func (self *tcpSession) protectedReadMessage() (msg interface{}, id int, err error) {
 
 	defer func() {
@@ -121,6 +239,9 @@ func (self *tcpSession) recvLoop() {
 		capturePanic = i.CaptureIOPanic()
 	}
 
+	// 对方断开前发来的关闭帧
+	var remoteFrame *cellnet.SessionCloseFrame
+
 	for self.Conn() != nil {
 
 		var msg interface{}
@@ -133,6 +254,12 @@ func (self *tcpSession) recvLoop() {
 			msg, id, err = self.ReadMessage(self)
 		}
 
+		var drop bool
+		msg, drop, err = peer.ResolveDecodeError(self, msg, err)
+		if drop {
+			continue
+		}
+
 		if err != nil {
 			if !util.IsEOFOrNetReadError(err) {
 
@@ -147,18 +274,29 @@ func (self *tcpSession) recvLoop() {
 				log.Errorf("session closed, sesid: %d, err: %s ip: %s", self.ID(), err, ip)
 			}
 
-			self.sendQueue.Add(nil)
+			closedMsg := self.makeClosedMsg(err, remoteFrame)
 
-			// 标记为手动关闭原因
-			closedMsg := &cellnet.SessionClosed{}
-			if self.IsManualClosed() {
-				closedMsg.Reason = cellnet.CloseReason_Manual
+			// 本端发现的错误(超时, 封包过大, 解码失败)通知对方
+			if closedMsg.Initiator == cellnet.CloseInitiator_Local && closedMsg.Err != nil {
+				self.closeWith(closedMsg.Reason, 0, true)
 			}
 
+			self.sendQueue.Add(nil)
+
 			self.ProcEvent(&cellnet.RecvMsgEvent{Ses: self, Id: 0, Msg: closedMsg})
 			break
 		}
 
+		if frame, ok := msg.(*cellnet.SessionCloseFrame); ok {
+			remoteFrame = frame
+			continue
+		}
+
+		// 等待发送完成时, 不再处理收到的消息
+		if self.IsManualClosed() {
+			continue
+		}
+
 		self.ProcEvent(&cellnet.RecvMsgEvent{Ses: self, Id: id, Msg: msg})
 	}
 
@@ -196,6 +334,14 @@ func (self *tcpSession) sendLoop() {
 		// 遍历要发送的数据
 		for _, msg := range writeList {
 
+			if _, ok := msg.(flushMark); ok {
+				if conn, ok := self.Conn().(*net.TCPConn); ok {
+					conn.CloseWrite()
+				}
+
+				continue
+			}
+
 			if capturePanic {
 				self.protectedSendMessage(&cellnet.SendMsgEvent{Ses: self, Msg: msg})
 			} else {
@@ -208,6 +354,13 @@ func (self *tcpSession) sendLoop() {
 		}
 	}
 
+	self.closeGuard.Lock()
+	if self.flushTimer != nil {
+		self.flushTimer.Stop()
+		self.flushTimer = nil
+	}
+	self.closeGuard.Unlock()
+
 	// 完整关闭
 	conn := self.Conn()
 	if conn != nil {
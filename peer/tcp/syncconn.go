@@ -62,6 +62,10 @@ func (self *tcpSyncConnector) SetSessionManager(raw interface{}) {
 	self.SessionManager = raw.(peer.SessionManager)
 }
 
+func (self *tcpSyncConnector) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
+}
+
 func (self *tcpSyncConnector) ReconnectDuration() time.Duration {
 	return 0
 }
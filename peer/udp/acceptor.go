@@ -12,7 +12,7 @@ import (
 const MaxUDPRecvBuffer = 2048
 
 type udpAcceptor struct {
-	peer.CoreSessionManager
+	peer.SessionManager
 	peer.CorePeerProperty
 	peer.CoreContextSet
 	peer.CoreRunningTag
@@ -28,6 +28,15 @@ type udpAcceptor struct {
 	sesByConnTrack map[connTrackKey]*udpSession
 }
 
+// 设置会话管理器, 实现peer.SessionManager接口, 在Start之前调用
+func (self *udpAcceptor) SetSessionManager(raw interface{}) {
+	self.SessionManager = raw.(peer.SessionManager)
+}
+
+func (self *udpAcceptor) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
+}
+
 func (self *udpAcceptor) IsReady() bool {
 
 	return self.IsRunning()
@@ -97,7 +106,7 @@ func (self *udpAcceptor) accept() {
 			break
 		}
 
-		self.checkTimeoutSession()
+		self.checkTimeoutSession(false)
 
 		if n > 0 {
 
@@ -113,25 +122,29 @@ func (self *udpAcceptor) accept() {
 
 	}
 
+	// 停止后移除所有会话, 会话同时离开分组
+	self.checkTimeoutSession(true)
+
 	self.SetRunning(false)
 
 }
 
-// 检查超时session
-func (self *udpAcceptor) checkTimeoutSession() {
+// 检查超时session, all为true时移除所有session
+func (self *udpAcceptor) checkTimeoutSession(all bool) {
 	now := time.Now()
 
 	// 定时清理超时的session
-	if now.After(self.sesCleanLastTime.Add(self.sesCleanTimeout)) {
+	if all || now.After(self.sesCleanLastTime.Add(self.sesCleanTimeout)) {
 		sesToDelete := make([]*udpSession, 0, 10)
 		for _, ses := range self.sesByConnTrack {
-			if !ses.IsAlive() {
+			if all || !ses.IsAlive() {
 				sesToDelete = append(sesToDelete, ses)
 			}
 		}
 
 		for _, ses := range sesToDelete {
 			delete(self.sesByConnTrack, *ses.key)
+			self.Remove(ses)
 		}
 
 		self.sesCleanLastTime = now
@@ -152,6 +165,9 @@ func (self *udpAcceptor) getSession(addr *net.UDPAddr) *udpSession {
 		ses.CoreProcBundle = &self.CoreProcBundle
 		ses.key = key
 		self.sesByConnTrack[*key] = ses
+
+		// 分配ID, 之后可以通过ID访问及加入分组
+		self.Add(ses)
 	}
 
 	// 续租
@@ -186,6 +202,7 @@ func init() {
 
 	peer.RegisterPeerCreator(func() cellnet.Peer {
 		p := &udpAcceptor{
+			SessionManager:   new(peer.CoreSessionManager),
 			sesTimeout:       time.Minute,
 			sesCleanTimeout:  time.Minute,
 			sesCleanLastTime: time.Now(),
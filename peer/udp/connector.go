@@ -8,7 +8,7 @@ import (
 )
 
 type udpConnector struct {
-	peer.CoreSessionManager
+	peer.SessionManager
 	peer.CorePeerProperty
 	peer.CoreContextSet
 	peer.CoreRunningTag
@@ -39,6 +39,15 @@ func (self *udpConnector) Session() cellnet.Session {
 	return self.defaultSes
 }
 
+// 设置会话管理器, 实现peer.SessionManager接口, 在Start之前调用
+func (self *udpConnector) SetSessionManager(raw interface{}) {
+	self.SessionManager = raw.(peer.SessionManager)
+}
+
+func (self *udpConnector) GetSessionManager() peer.SessionManager {
+	return self.SessionManager
+}
+
 func (self *udpConnector) IsReady() bool {
 
 	return self.defaultSes.Conn() != nil
@@ -57,6 +66,9 @@ func (self *udpConnector) connect() {
 
 	ses := self.defaultSes
 
+	self.Add(ses)
+	defer self.Remove(ses)
+
 	//self.ProcEvent(&cellnet.RecvMsgEvent{ses, &cellnet.SessionConnected{}})
 
 	self.ProcEvent(&cellnet.RecvMsgEvent{Ses: ses, Msg: &cellnet.SessionConnected{}})
@@ -95,7 +107,9 @@ func (self *udpConnector) TypeName() string {
 func init() {
 
 	peer.RegisterPeerCreator(func() cellnet.Peer {
-		p := &udpConnector{}
+		p := &udpConnector{
+			SessionManager: new(peer.CoreSessionManager),
+		}
 
 		p.defaultSes = &udpSession{
 			pInterface:     p,
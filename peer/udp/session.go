@@ -21,6 +21,7 @@ type DataWriter interface {
 type udpSession struct {
 	*peer.CoreProcBundle
 	peer.CoreContextSet
+	peer.CoreSessionIdentify
 
 	pInterface cellnet.Peer
 
@@ -50,10 +51,6 @@ func (self *udpSession) IsAlive() bool {
 	return time.Now().Before(self.timeOutTick)
 }
 
-func (self *udpSession) ID() int64 {
-	return 0
-}
-
 func (self *udpSession) LocalAddress() net.Addr {
 	return self.Conn().LocalAddr()
 }
@@ -73,7 +70,10 @@ func (self *udpSession) Recv(data []byte) {
 
 	msg, id, err := self.ReadMessage(self)
 
-	if msg != nil && err == nil {
+	// udp没有连接, 断开策略只丢弃
+	msg, drop, err := peer.ResolveDecodeError(self, msg, err)
+
+	if msg != nil && err == nil && !drop {
 		self.ProcEvent(&cellnet.RecvMsgEvent{self, id, msg})
 	}
 }
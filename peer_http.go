@@ -22,6 +22,9 @@ type HTTPAcceptor interface {
 
 	// 设置模板函数入口
 	SetTemplateFunc(f []template.FuncMap)
+
+	// 设置消息注册信息的访问地址, GET时以JSON返回所有注册的消息, 可以使用rule参数按消息全名正则过滤
+	SetMessageMetaServe(path string)
 }
 
 type HTTPRequest struct {
@@ -23,6 +23,9 @@ type TCPAcceptor interface {
 
 	TCPSocketOption
 
+	// 设置会话管理器 实现peer.SessionManager接口, 在Start之前设置
+	SetSessionManager(raw interface{})
+
 	// 查看当前侦听端口，使用host:0 作为Address时，socket底层自动分配侦听端口
 	Port() int
 }
@@ -8,6 +8,9 @@ type UDPConnector interface {
 
 	// 默认会话
 	Session() Session
+
+	// 设置会话管理器 实现peer.SessionManager接口, 在Start之前设置
+	SetSessionManager(raw interface{})
 }
 
 // UDP接受器
@@ -15,4 +18,7 @@ type UDPAcceptor interface {
 
 	// 底层使用TTL做session生命期管理，超时时间越短，内存占用越低
 	SetSessionTTL(dur time.Duration)
+
+	// 设置会话管理器 实现peer.SessionManager接口, 在Start之前设置
+	SetSessionManager(raw interface{})
 }
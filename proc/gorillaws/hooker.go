@@ -3,6 +3,7 @@ package gorillaws
 import (
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/msglog"
+	"github.com/luis-quan/cellnet/schema"
 )
 
 // 带有RPC和relay功能
@@ -11,6 +12,17 @@ type MsgHooker struct {
 
 func (self MsgHooker) OnInboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event) {
 
+	inputEvent, err := schema.ResolveInboundEvent(inputEvent)
+
+	if err != nil {
+		log.Errorln("schema.ResolveInboundEvent:", err)
+		return nil
+	}
+
+	if inputEvent == nil {
+		return nil
+	}
+
 	msglog.WriteRecvLogger(log, "ws", inputEvent.Session(), inputEvent.Message())
 
 	return inputEvent
@@ -73,6 +73,10 @@ func (WSMessageTransmitter) OnRecvMessage(ses cellnet.Session) (msg interface{},
 		msgData := raw[MsgIDSize:]
 		msg, _, err = codec.DecodeMessage(int(msgID), msgData)
 		id = int(msgID)
+
+		if err != nil {
+			err = &util.DecodeError{MsgID: id, Size: len(msgData), Err: err}
+		}
 	}
 
 	return
@@ -4,7 +4,10 @@ import (
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/msglog"
 	"github.com/luis-quan/cellnet/relay"
+	"github.com/luis-quan/cellnet/reliable"
+	"github.com/luis-quan/cellnet/resume"
 	"github.com/luis-quan/cellnet/rpc"
+	"github.com/luis-quan/cellnet/schema"
 )
 
 // 带有RPC和relay功能
@@ -16,6 +19,29 @@ func (self MsgHooker) OnInboundEvent(inputEvent cellnet.Event) (outputEvent cell
 	var handled bool
 	var err error
 
+	// 握手完成前暂缓连接事件
+	inputEvent, err = schema.ResolveInboundEvent(inputEvent)
+
+	if err != nil {
+		log.Errorln("schema.ResolveInboundEvent:", err)
+		return
+	}
+
+	if inputEvent == nil {
+		return
+	}
+
+	inputEvent, err = reliable.ResolveInboundEvent(inputEvent)
+
+	if err != nil {
+		log.Errorln("reliable.ResolveInboundEvent:", err)
+		return
+	}
+
+	if inputEvent == nil {
+		return
+	}
+
 	inputEvent, handled, err = rpc.ResolveInboundEvent(inputEvent)
 
 	if err != nil {
@@ -63,5 +89,50 @@ func (self MsgHooker) OnOutboundEvent(inputEvent cellnet.Event) (outputEvent cel
 		}
 	}
 
-	return inputEvent
+	// 记录日志后再封装可靠消息
+	outputEvent, err = reliable.ResolveOutboundEvent(inputEvent)
+
+	if err != nil {
+		log.Errorln("reliable.ResolveOutboundEvent:", err)
+		return nil
+	}
+
+	return
+}
+
+// 带有会话恢复, RPC和relay功能
+type ResumeMsgHooker struct {
+	MsgHooker
+}
+
+func (self ResumeMsgHooker) OnInboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event) {
+
+	inputEvent, err := resume.ResolveInboundEvent(inputEvent)
+
+	if err != nil {
+		log.Errorln("resume.ResolveInboundEvent:", err)
+		return nil
+	}
+
+	if inputEvent == nil {
+		return nil
+	}
+
+	return self.MsgHooker.OnInboundEvent(inputEvent)
+}
+
+func (self ResumeMsgHooker) OnOutboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event) {
+
+	inputEvent, err := resume.ResolveOutboundEvent(inputEvent)
+
+	if err != nil {
+		log.Errorln("resume.ResolveOutboundEvent:", err)
+		return nil
+	}
+
+	if inputEvent == nil {
+		return nil
+	}
+
+	return self.MsgHooker.OnOutboundEvent(inputEvent)
 }
@@ -3,6 +3,7 @@ package tcp
 import (
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/proc"
+	"github.com/luis-quan/cellnet/rpc"
 )
 
 func init() {
@@ -11,7 +12,16 @@ func init() {
 
 		bundle.SetTransmitter(new(TCPMessageTransmitter))
 		bundle.SetHooker(new(MsgHooker))
-		bundle.SetCallback(proc.NewQueuedEventCallback(userCallback))
+		bundle.SetCallback(proc.NewQueuedEventCallback(rpc.NewInterceptedEventCallback(userCallback)))
+
+	})
+
+	// 可恢复会话, 连接断开后在宽限期内重连可以恢复原来的逻辑会话, 双方需要使用相同的处理器
+	proc.RegisterProcessor("tcp.ltv.resume", func(bundle proc.ProcessorBundle, userCallback cellnet.EventCallback, args ...interface{}) {
+
+		bundle.SetTransmitter(new(TCPMessageTransmitter))
+		bundle.SetHooker(new(ResumeMsgHooker))
+		bundle.SetCallback(proc.NewQueuedEventCallback(rpc.NewInterceptedEventCallback(userCallback)))
 
 	})
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 
 	"github.com/luis-quan/cellnet/codec"
+	"github.com/luis-quan/cellnet/util"
 )
 
 const (
@@ -17,8 +18,8 @@ const (
 func RecvPacket(pktData []byte) (msg interface{}, id int, err error) {
 
 	// 小于包头，使用nc指令测试时，为1
-	if len(pktData) < packetLen {
-		return nil, 0, nil
+	if len(pktData) < HeaderSize {
+		return nil, 0, &util.DecodeError{Size: len(pktData), Err: util.ErrMinPacket}
 	}
 
 	// 用小端格式读取Size
@@ -26,7 +27,7 @@ func RecvPacket(pktData []byte) (msg interface{}, id int, err error) {
 
 	// 出错，等待下次数据
 	if int(datasize) != len(pktData) || datasize > MTU {
-		return nil, 0, nil
+		return nil, 0, &util.DecodeError{Size: len(pktData), Err: util.ErrPacketSize}
 	}
 
 	// 读取消息ID
@@ -37,8 +38,7 @@ func RecvPacket(pktData []byte) (msg interface{}, id int, err error) {
 	// 将字节数组和消息ID用户解出消息
 	msg, _, err = codec.DecodeMessage(int(msgid), msgData)
 	if err != nil {
-		// TODO 接收错误时，返回消息
-		return nil, 0, err
+		return nil, 0, &util.DecodeError{MsgID: id, Size: len(msgData), Err: err}
 	}
 
 	return
@@ -10,12 +10,28 @@ import (
 
 func sendPacket(writer udp.DataWriter, ctx cellnet.ContextSet, msg interface{}) error {
 
-	// 将用户数据转换为字节数组和消息ID
-	msgData, meta, err := codec.EncodeMessage(msg, ctx)
-
-	if err != nil {
-		log.Errorf("send message encode error: %s", err)
-		return err
+	var (
+		msgData []byte
+		msgID   int
+		meta    *cellnet.MessageMeta
+	)
+
+	switch m := msg.(type) {
+	case *cellnet.RawPacket: // 发裸包
+		msgData = m.MsgData
+		msgID = m.MsgID
+	default: // 发普通编码包
+		var err error
+
+		// 将用户数据转换为字节数组和消息ID
+		msgData, meta, err = codec.EncodeMessage(msg, ctx)
+
+		if err != nil {
+			log.Errorf("send message encode error: %s", err)
+			return err
+		}
+
+		msgID = meta.ID
 	}
 
 	pktData := make([]byte, HeaderSize+len(msgData))
@@ -24,14 +40,16 @@ func sendPacket(writer udp.DataWriter, ctx cellnet.ContextSet, msg interface{})
 	binary.LittleEndian.PutUint16(pktData, uint16(HeaderSize+len(msgData)))
 
 	// Type
-	binary.LittleEndian.PutUint16(pktData[2:], uint16(meta.ID))
+	binary.LittleEndian.PutUint16(pktData[2:], uint16(msgID))
 
 	// Value
 	copy(pktData[HeaderSize:], msgData)
 
 	writer.WriteData(pktData)
 
-	codec.FreeCodecResource(meta.Codec, msgData, ctx)
+	if meta != nil {
+		codec.FreeCodecResource(meta.Codec, msgData, ctx)
+	}
 
 	return nil
 }
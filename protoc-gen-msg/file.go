@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"go/parser"
 	"go/printer"
@@ -9,7 +10,7 @@ import (
 	"text/template"
 
 	"github.com/davyxu/pbmeta"
-	"github.com/luis-quan/cellnet/util"
+	pbprotos "github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
 )
 
 const codeTemplate = `// Generated by github.com/luis-quan/cellnet/protoc-gen-msg
@@ -23,7 +24,10 @@ import (
 	"github.com/luis-quan/cellnet"
 	"reflect"
 	_ "github.com/luis-quan/cellnet/codec/gogopb"
-	"github.com/luis-quan/cellnet/codec"
+	"github.com/luis-quan/cellnet/codec"{{if .Services}}
+	"github.com/luis-quan/cellnet/proc"
+	"github.com/luis-quan/cellnet/rpc"
+	"time"{{end}}
 )
 {{end}}
 
@@ -36,17 +40,93 @@ func init() {
 		ID: {{.MsgID}},
 	}){{end}} {{end}}
 }
+{{range .Services}}
+// {{.Name}}服务的客户端
+type {{.Name}}Client struct {
+	SesOrPeer interface{} // peer/session
+	Timeout   time.Duration
+}
+
+func New{{.Name}}Client(sesOrPeer interface{}, timeout time.Duration) *{{.Name}}Client {
+	return &{{.Name}}Client{
+		SesOrPeer: sesOrPeer,
+		Timeout:   timeout,
+	}
+}
+{{$svc := .}}{{range .Methods}}{{if .Stream}}
+// 打开{{.Name}}流
+func (self *{{$svc.Name}}Client) {{.Name}}(req *{{.InputType}}) (*rpc.Stream, error) {
+	return rpc.OpenStream(self.SesOrPeer, req)
+}
+{{else}}
+// 同步请求{{.Name}}
+func (self *{{$svc.Name}}Client) {{.Name}}(req *{{.InputType}}) (*{{.OutputType}}, error) {
+
+	raw, err := rpc.CallSync(self.SesOrPeer, req, self.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	ack, ok := raw.(*{{.OutputType}})
+	if !ok {
+		return nil, rpc.ErrUnexpectedReply
+	}
+
+	return ack, nil
+}
+
+// 异步请求{{.Name}}, 回调在会话的队列中执行
+func (self *{{$svc.Name}}Client) {{.Name}}Async(req *{{.InputType}}, callback func(*{{.OutputType}}, error)) {
+
+	rpc.Call(self.SesOrPeer, req, self.Timeout, func(raw interface{}) {
+		switch ack := raw.(type) {
+		case *{{.OutputType}}:
+			callback(ack, nil)
+		case error:
+			callback(nil, ack)
+		default:
+			callback(nil, rpc.ErrUnexpectedReply)
+		}
+	})
+}
+{{end}}{{end}}
+// {{.Name}}服务的服务器接口, 返回*rpc.CallError时调用方收到对应状态码
+// 流式方法在队列中调用, Stream的Recv会阻塞, 需要在独立goroutine中读写
+type {{.Name}}Server interface { {{range .Methods}}{{if .Stream}}
+	{{.Name}}(ev *rpc.RecvStreamEvent, req *{{.InputType}}){{else}}
+	{{.Name}}(ev *rpc.RecvMsgEvent, req *{{.InputType}}) (*{{.OutputType}}, error){{end}}{{end}}
+}
 
+// 将{{.Name}}服务注册到消息派发器
+func Register{{.Name}}Server(dispatcher *proc.MessageDispatcher, server {{.Name}}Server) {
+{{range .Methods}}
+	dispatcher.RegisterMessage("{{.InputFullName}}", func(ev cellnet.Event) { {{if .Stream}}
+		if streamEv, ok := ev.(*rpc.RecvStreamEvent); ok {
+			server.{{.Name}}(streamEv, streamEv.Msg.(*{{.InputType}}))
+		}{{else}}
+		if rpcEv, ok := ev.(*rpc.RecvMsgEvent); ok {
+			ack, err := server.{{.Name}}(rpcEv, rpcEv.Msg.(*{{.InputType}}))
+			if ack == nil && err == nil {
+				err = &rpc.CallError{Code: rpc.StatusCode_Internal, Msg: "{{$svc.Name}}.{{.Name}} returned nil reply"}
+			}
+			rpcEv.ReplyResult(ack, err)
+		}{{end}}
+	})
+{{end}}}
+{{end}}
 `
 
 type msgModel struct {
 	*pbmeta.Descriptor
 
 	parent *pbmeta.FileDescriptor
+
+	ID     int
+	Source string // ID的来源
 }
 
 func (self *msgModel) MsgID() int {
-	return int(util.StringHash(self.FullName()))
+	return self.ID
 }
 
 func (self *msgModel) FullName() string {
@@ -67,27 +147,40 @@ type fileModel struct {
 	TotalMessages int
 	Protos        []*protoModel
 	PackageName   string
+	Services      []*serviceModel
 }
 
-func printFile(pool *pbmeta.DescriptorPool) (string, bool) {
+// 只为需要生成的文件生成服务代码, 不包含依赖的文件
+// 注册消息时只包含与生成文件同一个包的文件, 忽略msgid.proto及google/protobuf等其他包的依赖
+func buildModel(pool *pbmeta.DescriptorPool, protoFiles []*pbprotos.FileDescriptorProto, fileToGenerate []string, idmap map[string]int) (*fileModel, error) {
 
-	tpl, err := template.New("msgid").Parse(codeTemplate)
-	if err != nil {
-		log.Errorln(err)
-		return "", false
+	if pool.FileCount() == 0 {
+		return nil, errors.New("no proto file")
 	}
 
-	if pool.FileCount() == 0 {
-		return "", false
+	generate := make(map[string]bool)
+	for _, name := range fileToGenerate {
+		generate[name] = true
 	}
 
 	var model fileModel
 	model.PackageName = pool.File(0).PackageName()
 
+	for _, file := range protoFiles {
+		if generate[file.GetName()] {
+			model.PackageName = file.GetPackage()
+			break
+		}
+	}
+
 	for f := 0; f < pool.FileCount(); f++ {
 
 		file := pool.File(f)
 
+		if file.PackageName() != model.PackageName {
+			continue
+		}
+
 		pm := &protoModel{
 			FileDescriptor: file,
 		}
@@ -109,21 +202,62 @@ func printFile(pool *pbmeta.DescriptorPool) (string, bool) {
 
 	}
 
+	if err := assignMsgIDs(&model, protoFiles, idmap); err != nil {
+		return nil, err
+	}
+
+	// 请求类型对应的方法
+	reqTypes := make(map[string]string)
+
+	for _, file := range protoFiles {
+
+		if !generate[file.GetName()] {
+			continue
+		}
+
+		for _, sd := range file.GetService() {
+
+			sm, err := newServiceModel(file, sd, reqTypes)
+			if err != nil {
+				return nil, err
+			}
+
+			model.Services = append(model.Services, sm)
+		}
+	}
+
+	return &model, nil
+}
+
+func printFile(pool *pbmeta.DescriptorPool, protoFiles []*pbprotos.FileDescriptorProto, fileToGenerate []string, idmap map[string]int) (string, *fileModel, bool) {
+
+	tpl, err := template.New("msgid").Parse(codeTemplate)
+	if err != nil {
+		log.Errorln(err)
+		return "", nil, false
+	}
+
+	model, err := buildModel(pool, protoFiles, fileToGenerate, idmap)
+	if err != nil {
+		log.Errorln(err)
+		return "", nil, false
+	}
+
 	var bf bytes.Buffer
 
-	err = tpl.Execute(&bf, &model)
+	err = tpl.Execute(&bf, model)
 	if err != nil {
 		log.Errorln(err)
-		return "", false
+		return "", nil, false
 	}
 
 	err = formatCode(&bf)
 	if err != nil {
 		log.Errorln(err)
-		return "", false
+		return "", nil, false
 	}
 
-	return bf.String(), true
+	return bf.String(), model, true
 }
 
 func formatCode(bf *bytes.Buffer) error {
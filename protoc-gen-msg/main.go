@@ -17,6 +17,7 @@ var log = golog.New("main")
 
 func main() {
 
+	// 错误通过Response.Error返回给protoc, 不能使用os.Exit退出, 否则不会发回处理结果
 	var errBuffer bytes.Buffer
 
 	golog.SetOutput("main", &errBuffer)
@@ -42,18 +43,30 @@ func main() {
 	data, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		log.Errorln("reading input")
-		os.Exit(1)
+		return
 	}
 
 	// 解析请求
 	if err := proto.Unmarshal(data, &Request); err != nil {
 		log.Errorln("parsing input proto")
-		os.Exit(1)
+		return
 	}
 
 	if len(Request.FileToGenerate) == 0 {
 		log.Errorln("no files to generate")
-		os.Exit(1)
+		return
+	}
+
+	param, err := parseParameter(Request.GetParameter())
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	idmap, err := loadIDMap(param.IDMapFile)
+	if err != nil {
+		log.Errorln(err)
+		return
 	}
 
 	// 建立解析池
@@ -63,15 +76,28 @@ func main() {
 
 	Response.File = make([]*plugin.CodeGeneratorResponse_File, 0)
 
-	contenxt, ok := printFile(pool)
+	contenxt, model, ok := printFile(pool, Request.ProtoFile, Request.FileToGenerate, idmap)
 
 	if !ok {
-		os.Exit(1)
+		return
 	}
 
 	Response.File = append(Response.File, &plugin.CodeGeneratorResponse_File{
-		Name:    proto.String(Request.GetParameter()),
+		Name:    proto.String(param.OutputFile),
 		Content: proto.String(contenxt),
 	})
 
+	if param.ManifestFile != "" {
+
+		data, err := makeManifest(model)
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+
+		Response.File = append(Response.File, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(param.ManifestFile),
+			Content: proto.String(string(data)),
+		})
+	}
 }
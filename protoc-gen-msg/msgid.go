@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	pbprotos "github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/luis-quan/cellnet"
+	_ "github.com/luis-quan/cellnet/peer" // 系统消息
+	_ "github.com/luis-quan/cellnet/relay"
+	_ "github.com/luis-quan/cellnet/reliable"
+	_ "github.com/luis-quan/cellnet/resume"
+	_ "github.com/luis-quan/cellnet/rpc"
+	_ "github.com/luis-quan/cellnet/schema"
+	"github.com/luis-quan/cellnet/util"
+)
+
+// 消息ID的来源
+const (
+	idSource_Hash   = "hash"   // 使用util.StringHash从完整消息名生成
+	idSource_Option = "option" // proto中的(cellnet.msgid)选项
+	idSource_IDMap  = "idmap"  // ID映射文件
+)
+
+// 在proto中指定消息ID, 定义见msgid.proto
+//
+//	message ChatREQ {
+//		option (cellnet.msgid) = 1001;
+//	}
+var E_MsgID = &proto.ExtensionDesc{
+	ExtendedType:  (*pbprotos.MessageOptions)(nil),
+	ExtensionType: (*uint32)(nil),
+	Field:         52001,
+	Name:          "cellnet.msgid",
+	Tag:           "varint,52001,opt,name=msgid",
+	Filename:      "msgid.proto",
+}
+
+// cellnet内建的消息, 用户消息ID不能与之冲突
+// 部分内建消息使用固定ID(例如rpc, relay), 与StringHash不同, 因此按实际注册的ID建立对照表
+func builtinMessageIDs() map[int]string {
+
+	nameByID := make(map[int]string)
+
+	cellnet.MessageMetaVisit("", func(meta *cellnet.MessageMeta) bool {
+		nameByID[meta.ID] = meta.FullName()
+		return true
+	})
+
+	return nameByID
+}
+
+// 插件参数, 例如: --msg_out=msgid.go,idmap=msgid.txt,manifest=msgid.json:.
+type genParam struct {
+	OutputFile   string // 生成的go文件
+	IDMapFile    string // 指定消息ID的映射文件
+	ManifestFile string // 导出的消息ID清单(JSON), 供其他语言的客户端使用
+}
+
+func parseParameter(param string) (*genParam, error) {
+
+	ret := &genParam{OutputFile: "msgid.go"}
+
+	for _, item := range strings.Split(param, ",") {
+
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pos := strings.Index(item, "=")
+		if pos == -1 {
+			ret.OutputFile = item
+			continue
+		}
+
+		key, value := item[:pos], item[pos+1:]
+
+		switch key {
+		case "idmap":
+			ret.IDMapFile = value
+		case "manifest":
+			ret.ManifestFile = value
+		default:
+			return nil, fmt.Errorf("unknown parameter '%s'", key)
+		}
+	}
+
+	return ret, nil
+}
+
+// 读取ID映射文件, 每行格式为: 完整消息名 = ID, #开头的行为注释
+func loadIDMap(filename string) (map[string]int, error) {
+
+	idmap := make(map[string]int)
+
+	if filename == "" {
+		return idmap, nil
+	}
+
+	var (
+		lineNo int
+		retErr error
+	)
+
+	err := util.ReadFileLines(filename, func(line string) bool {
+
+		lineNo++
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return true
+		}
+
+		pos := strings.Index(line, "=")
+		if pos == -1 {
+			retErr = fmt.Errorf("%s:%d: expect 'name = id'", filename, lineNo)
+			return false
+		}
+
+		name := strings.TrimSpace(line[:pos])
+
+		id, err := parseMsgID(strings.TrimSpace(line[pos+1:]))
+		if err != nil {
+			retErr = fmt.Errorf("%s:%d: %s", filename, lineNo, err)
+			return false
+		}
+
+		if _, ok := idmap[name]; ok {
+			retErr = fmt.Errorf("%s:%d: duplicate message '%s'", filename, lineNo, name)
+			return false
+		}
+
+		idmap[name] = id
+
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return idmap, retErr
+}
+
+// 封包中消息ID为uint16
+func parseMsgID(str string) (int, error) {
+
+	id, err := strconv.ParseUint(str, 10, 16)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid message id '%s', expect 1~65535", str)
+	}
+
+	return int(id), nil
+}
+
+// proto中(cellnet.msgid)选项指定的ID
+func optionMsgID(d *pbprotos.DescriptorProto) (int, bool, error) {
+
+	opts := d.GetOptions()
+	if opts == nil || !proto.HasExtension(opts, E_MsgID) {
+		return 0, false, nil
+	}
+
+	raw, err := proto.GetExtension(opts, E_MsgID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	id := *raw.(*uint32)
+	if id == 0 || id > 0xFFFF {
+		return 0, false, fmt.Errorf("invalid message id %d, expect 1~65535", id)
+	}
+
+	return int(id), true, nil
+}
+
+// 按 proto选项 > ID映射文件 > StringHash 的顺序分配消息ID, 并检查ID冲突
+func assignMsgIDs(model *fileModel, protoFiles []*pbprotos.FileDescriptorProto, idmap map[string]int) error {
+
+	descByName := make(map[string]*pbprotos.DescriptorProto)
+	for _, file := range protoFiles {
+		for _, d := range file.GetMessageType() {
+			descByName[file.GetPackage()+"."+d.GetName()] = d
+		}
+	}
+
+	nameByID := make(map[int]string)
+	for id, name := range builtinMessageIDs() {
+		nameByID[id] = name + "(builtin)"
+	}
+
+	var conflicts []string
+
+	for _, pm := range model.Protos {
+		for _, m := range pm.Messages {
+
+			fullName := m.FullName()
+
+			if d, ok := descByName[fullName]; ok {
+
+				id, ok, err := optionMsgID(d)
+				if err != nil {
+					return fmt.Errorf("%s: %s", fullName, err)
+				}
+
+				if ok {
+					m.ID, m.Source = id, idSource_Option
+				}
+			}
+
+			if m.Source == "" {
+				if id, ok := idmap[fullName]; ok {
+					m.ID, m.Source = id, idSource_IDMap
+				} else {
+					m.ID, m.Source = int(util.StringHash(fullName)), idSource_Hash
+				}
+			}
+
+			desc := fmt.Sprintf("%s(%s)", fullName, m.Source)
+
+			if exists, ok := nameByID[m.ID]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("id %d: %s conflicts with %s", m.ID, desc, exists))
+				continue
+			}
+
+			nameByID[m.ID] = desc
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("message id conflict, specify id by option (cellnet.msgid) or idmap file\n%s", strings.Join(conflicts, "\n"))
+	}
+
+	return nil
+}
+
+type manifestEntry struct {
+	ID     int
+	Name   string
+	File   string
+	Source string
+}
+
+type manifest struct {
+	Package  string
+	Messages []*manifestEntry
+}
+
+// 导出消息ID清单, 按消息名排序, 保证多次生成的结果一致
+func makeManifest(model *fileModel) ([]byte, error) {
+
+	m := &manifest{
+		Package: model.PackageName,
+	}
+
+	for _, pm := range model.Protos {
+		for _, msg := range pm.Messages {
+			m.Messages = append(m.Messages, &manifestEntry{
+				ID:     msg.ID,
+				Name:   msg.FullName(),
+				File:   pm.Name(),
+				Source: msg.Source,
+			})
+		}
+	}
+
+	sort.Slice(m.Messages, func(i, j int) bool {
+		return m.Messages[i].Name < m.Messages[j].Name
+	})
+
+	return json.MarshalIndent(m, "", "\t")
+}
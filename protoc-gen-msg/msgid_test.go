@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davyxu/pbmeta"
+	"github.com/gogo/protobuf/proto"
+	pbprotos "github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/util"
+)
+
+// id为0时不设置(cellnet.msgid)选项
+func testMessage(t *testing.T, name string, id uint32) *pbprotos.DescriptorProto {
+
+	d := &pbprotos.DescriptorProto{Name: proto.String(name)}
+
+	if id != 0 {
+		d.Options = &pbprotos.MessageOptions{}
+		if err := proto.SetExtension(d.Options, E_MsgID, &id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return d
+}
+
+func testFile(name string, msgs ...*pbprotos.DescriptorProto) *pbprotos.FileDescriptorProto {
+	return &pbprotos.FileDescriptorProto{
+		Name:        proto.String(name),
+		Package:     proto.String("gentest"),
+		MessageType: msgs,
+	}
+}
+
+func testBuildModel(files []*pbprotos.FileDescriptorProto, idmap map[string]int) (*fileModel, error) {
+
+	pool := pbmeta.NewDescriptorPool(&pbprotos.FileDescriptorSet{File: files})
+
+	return buildModel(pool, files, []string{files[0].GetName()}, idmap)
+}
+
+func testFindMsg(model *fileModel, fullName string) *msgModel {
+
+	for _, pm := range model.Protos {
+		for _, m := range pm.Messages {
+			if m.FullName() == fullName {
+				return m
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestAssignMsgID(t *testing.T) {
+
+	file := testFile("chat.proto",
+		testMessage(t, "HashREQ", 0),
+		testMessage(t, "OptionREQ", 1001),
+		testMessage(t, "IDMapREQ", 0),
+		testMessage(t, "BothREQ", 1003),
+	)
+
+	model, err := testBuildModel([]*pbprotos.FileDescriptorProto{file}, map[string]int{
+		"gentest.IDMapREQ": 1002,
+		"gentest.BothREQ":  1004,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		id     int
+		source string
+	}{
+		{"gentest.HashREQ", int(util.StringHash("gentest.HashREQ")), idSource_Hash},
+		{"gentest.OptionREQ", 1001, idSource_Option},
+		{"gentest.IDMapREQ", 1002, idSource_IDMap},
+		{"gentest.BothREQ", 1003, idSource_Option}, // proto选项优先
+	} {
+		m := testFindMsg(model, c.name)
+		if m == nil || m.ID != c.id || m.Source != c.source {
+			t.Fatalf("%s: expect %d(%s), got %+v", c.name, c.id, c.source, m)
+		}
+	}
+}
+
+func TestMsgIDConflict(t *testing.T) {
+
+	// 用户消息之间冲突
+	file := testFile("chat.proto",
+		testMessage(t, "ChatREQ", 0),
+		testMessage(t, "ChatACK", uint32(util.StringHash("gentest.ChatREQ"))),
+	)
+
+	_, err := testBuildModel([]*pbprotos.FileDescriptorProto{file}, nil)
+	if err == nil || !strings.Contains(err.Error(), "gentest.ChatACK(option) conflicts with gentest.ChatREQ(hash)") {
+		t.Fatal("expect conflict, got", err)
+	}
+
+	// 与固定ID的内建消息冲突, 内建消息的ID与StringHash不同
+	meta := cellnet.MessageMetaByFullName("rpc.RemoteCallREQ")
+	if meta == nil {
+		t.Fatal("builtin message not registered")
+	}
+
+	if meta.ID == int(util.StringHash("rpc.RemoteCallREQ")) {
+		t.Fatal("expect fixed builtin id")
+	}
+
+	file = testFile("chat.proto", testMessage(t, "ChatREQ", 0))
+
+	_, err = testBuildModel([]*pbprotos.FileDescriptorProto{file}, map[string]int{"gentest.ChatREQ": meta.ID})
+	if err == nil || !strings.Contains(err.Error(), "rpc.RemoteCallREQ(builtin)") {
+		t.Fatal("expect builtin conflict, got", err)
+	}
+
+	// 与系统消息冲突
+	file = testFile("chat.proto", testMessage(t, "ChatREQ", uint32(util.StringHash("cellnet.SessionClosed"))))
+
+	_, err = testBuildModel([]*pbprotos.FileDescriptorProto{file}, nil)
+	if err == nil || !strings.Contains(err.Error(), "cellnet.SessionClosed(builtin)") {
+		t.Fatal("expect builtin conflict, got", err)
+	}
+}
+
+func TestParseParameter(t *testing.T) {
+
+	param, err := parseParameter("out.go, idmap=msgid.txt,manifest=msgid.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if param.OutputFile != "out.go" || param.IDMapFile != "msgid.txt" || param.ManifestFile != "msgid.json" {
+		t.Fatalf("unexpected param %+v", param)
+	}
+
+	if param, _ = parseParameter(""); param.OutputFile != "msgid.go" {
+		t.Fatal("expect default output file, got", param.OutputFile)
+	}
+
+	if _, err = parseParameter("unknown=1"); err == nil {
+		t.Fatal("expect unknown parameter error")
+	}
+}
+
+func TestLoadIDMap(t *testing.T) {
+
+	dir := t.TempDir()
+
+	write := func(content string) string {
+		filename := filepath.Join(dir, "msgid.txt")
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return filename
+	}
+
+	idmap, err := loadIDMap(write("# comment\n\ngentest.ChatREQ = 1001\n  gentest.ChatACK=65535  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(idmap) != 2 || idmap["gentest.ChatREQ"] != 1001 || idmap["gentest.ChatACK"] != 65535 {
+		t.Fatalf("unexpected idmap %v", idmap)
+	}
+
+	for _, c := range []struct {
+		content string
+		expect  string
+	}{
+		{"gentest.ChatREQ 1001\n", ":1: expect 'name = id'"},
+		{"gentest.ChatREQ = 0\n", "invalid message id '0'"},
+		{"gentest.ChatREQ = 65536\n", "invalid message id '65536'"},
+		{"gentest.ChatREQ = abc\n", "invalid message id 'abc'"},
+		{"gentest.ChatREQ = 1\ngentest.ChatREQ = 2\n", ":2: duplicate message 'gentest.ChatREQ'"},
+	} {
+		if _, err := loadIDMap(write(c.content)); err == nil || !strings.Contains(err.Error(), c.expect) {
+			t.Fatalf("%q: expect %q, got %v", c.content, c.expect, err)
+		}
+	}
+
+	if idmap, err := loadIDMap(""); err != nil || len(idmap) != 0 {
+		t.Fatal("expect empty idmap", idmap, err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+
+	files := []*pbprotos.FileDescriptorProto{
+		testFile("chat.proto", testMessage(t, "ChatREQ", 1001), testMessage(t, "AckREQ", 0)),
+		testFile("login.proto", testMessage(t, "LoginREQ", 0)),
+	}
+
+	model, err := testBuildModel(files, map[string]int{"gentest.LoginREQ": 2001})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := makeManifest(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []manifestEntry{
+		{int(util.StringHash("gentest.AckREQ")), "gentest.AckREQ", "chat.proto", idSource_Hash},
+		{1001, "gentest.ChatREQ", "chat.proto", idSource_Option},
+		{2001, "gentest.LoginREQ", "login.proto", idSource_IDMap},
+	}
+
+	if m.Package != "gentest" || len(m.Messages) != len(expect) {
+		t.Fatalf("unexpected manifest %s", data)
+	}
+
+	for i, entry := range m.Messages {
+		if *entry != expect[i] {
+			t.Fatalf("expect %+v, got %+v", expect[i], *entry)
+		}
+	}
+}
+
+func TestGenerateService(t *testing.T) {
+
+	file := testFile("svc.proto",
+		testMessage(t, "EchoREQ", 0),
+		testMessage(t, "EchoACK", 0),
+		testMessage(t, "TailREQ", 0),
+		testMessage(t, "TailACK", 0),
+	)
+
+	file.Service = []*pbprotos.ServiceDescriptorProto{{
+		Name: proto.String("Echo"),
+		Method: []*pbprotos.MethodDescriptorProto{
+			{Name: proto.String("Echo"), InputType: proto.String(".gentest.EchoREQ"), OutputType: proto.String(".gentest.EchoACK")},
+			{Name: proto.String("Tail"), InputType: proto.String(".gentest.TailREQ"), OutputType: proto.String(".gentest.TailACK"), ServerStreaming: proto.Bool(true)},
+		},
+	}}
+
+	files := []*pbprotos.FileDescriptorProto{file}
+	pool := pbmeta.NewDescriptorPool(&pbprotos.FileDescriptorSet{File: files})
+
+	code, _, ok := printFile(pool, files, []string{"svc.proto"}, nil)
+	if !ok {
+		t.Fatal("generate failed")
+	}
+
+	for _, expect := range []string{
+		"func NewEchoClient(sesOrPeer interface{}, timeout time.Duration) *EchoClient",
+		"func (self *EchoClient) Echo(req *EchoREQ) (*EchoACK, error)",
+		"func (self *EchoClient) EchoAsync(req *EchoREQ, callback func(*EchoACK, error))",
+		"func (self *EchoClient) Tail(req *TailREQ) (*rpc.Stream, error)",
+		"Echo(ev *rpc.RecvMsgEvent, req *EchoREQ) (*EchoACK, error)",
+		"Tail(ev *rpc.RecvStreamEvent, req *TailREQ)",
+		"func RegisterEchoServer(dispatcher *proc.MessageDispatcher, server EchoServer)",
+		`dispatcher.RegisterMessage("gentest.EchoREQ"`,
+		// 回应和错误都为nil时, 回应内部错误, 避免调用方等待超时
+		`err = &rpc.CallError{Code: rpc.StatusCode_Internal, Msg: "Echo.Echo returned nil reply"}`,
+	} {
+		if !strings.Contains(code, expect) {
+			t.Fatalf("expect %q in generated code:\n%s", expect, code)
+		}
+	}
+
+	// 请求类型被多个方法使用
+	file.Service[0].Method[1].InputType = proto.String(".gentest.EchoREQ")
+
+	if _, err := testBuildModel(files, nil); err == nil || !strings.Contains(err.Error(), "already used by Echo.Echo") {
+		t.Fatal("expect duplicate request type, got", err)
+	}
+
+	// 使用其他包的消息
+	file.Service[0].Method[1].InputType = proto.String(".other.TailREQ")
+
+	if _, err := testBuildModel(files, nil); err == nil || !strings.Contains(err.Error(), "must be declared in package 'gentest'") {
+		t.Fatal("expect package error, got", err)
+	}
+}
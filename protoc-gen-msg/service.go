@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	pbprotos "github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+type methodModel struct {
+	Name string
+
+	// 请求及回应的go类型名
+	InputType  string
+	OutputType string
+
+	// 请求消息的完整名, 用于注册到消息派发器
+	InputFullName string
+
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// 流式方法使用rpc.Stream
+func (self *methodModel) Stream() bool {
+	return self.ClientStreaming || self.ServerStreaming
+}
+
+type serviceModel struct {
+	Name    string
+	Methods []*methodModel
+}
+
+// 服务的方法只能使用本包的消息
+func resolveTypeName(pkgName, typeName string) (string, error) {
+
+	prefix := "."
+	if pkgName != "" {
+		prefix = "." + pkgName + "."
+	}
+
+	if !strings.HasPrefix(typeName, prefix) || strings.Contains(typeName[len(prefix):], ".") {
+		return "", fmt.Errorf("type '%s' must be declared in package '%s'", typeName, pkgName)
+	}
+
+	return typeName[len(prefix):], nil
+}
+
+func newServiceModel(file *pbprotos.FileDescriptorProto, sd *pbprotos.ServiceDescriptorProto, reqTypes map[string]string) (*serviceModel, error) {
+
+	sm := &serviceModel{
+		Name: sd.GetName(),
+	}
+
+	pkgName := file.GetPackage()
+
+	for _, md := range sd.GetMethod() {
+
+		inputType, err := resolveTypeName(pkgName, md.GetInputType())
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", sm.Name, md.GetName(), err)
+		}
+
+		outputType, err := resolveTypeName(pkgName, md.GetOutputType())
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", sm.Name, md.GetName(), err)
+		}
+
+		mm := &methodModel{
+			Name:            md.GetName(),
+			InputType:       inputType,
+			OutputType:      outputType,
+			InputFullName:   strings.TrimPrefix(md.GetInputType(), "."),
+			ClientStreaming: md.GetClientStreaming(),
+			ServerStreaming: md.GetServerStreaming(),
+		}
+
+		// 消息派发器按请求类型派发, 请求类型不能被多个方法使用
+		methodName := sm.Name + "." + mm.Name
+		if exists, ok := reqTypes[mm.InputFullName]; ok {
+			return nil, fmt.Errorf("%s: request type '%s' already used by %s", methodName, mm.InputFullName, exists)
+		}
+
+		reqTypes[mm.InputFullName] = methodName
+
+		sm.Methods = append(sm.Methods, mm)
+	}
+
+	return sm, nil
+}
@@ -1,6 +1,8 @@
 package relay
 
 import (
+	"reflect"
+
 	"github.com/luis-quan/cellnet"
 )
 
@@ -8,6 +10,47 @@ type RecvMsgEvent struct {
 	Ses cellnet.Session
 	ack *RelayACK
 	Msg interface{}
+
+	// 第二个起的消息
+	extra []interface{}
+
+	passThroughMsgs []interface{}
+	passThroughMap  map[string]string
+}
+
+// 所有消息, 第一个与Msg相同
+func (self *RecvMsgEvent) Messages() (ret []interface{}) {
+
+	if self.Msg != nil {
+		ret = append(ret, self.Msg)
+	}
+
+	return append(ret, self.extra...)
+}
+
+// 透传的map[string]string, 多个时合并
+func (self *RecvMsgEvent) PassThroughAsMap() map[string]string {
+	return self.passThroughMap
+}
+
+// 透传的消息(通过PassThroughMsg传入)
+func (self *RecvMsgEvent) PassThroughMsgs() []interface{} {
+	return self.passThroughMsgs
+}
+
+// 透传消息中第一个与msgPtr类型相同的消息, 例如: var info UserInfo; ev.PassThroughAsMsg(&info)
+func (self *RecvMsgEvent) PassThroughAsMsg(msgPtr interface{}) bool {
+
+	dst := reflect.ValueOf(msgPtr)
+
+	for _, msg := range self.passThroughMsgs {
+		if reflect.TypeOf(msg) == dst.Type() {
+			dst.Elem().Set(reflect.ValueOf(msg).Elem())
+			return true
+		}
+	}
+
+	return false
 }
 
 func (self *RecvMsgEvent) PassThroughAsInt64() int64 {
@@ -42,10 +85,34 @@ func (self *RecvMsgEvent) Message() interface{} {
 	return self.Msg
 }
 
-// 消息原路返回
+// 路由键, 不是经过路由的消息时为0
+func (self *RecvMsgEvent) RouteKey() int64 {
+	if self.ack == nil {
+		return 0
+	}
+
+	return self.ack.RouteKey
+}
+
+// 消息原路返回, 透传值一并返回
 func (self *RecvMsgEvent) Reply(msg interface{}) {
 
 	// 没填的值不会被发送
-	Relay(self.Ses, msg, self.ack.Int64, self.ack.Int64Slice, self.ack.Str)
+	ack, err := buildACK([]interface{}{msg, self.ack.Int64, self.ack.Int64Slice, self.ack.Str})
+	if err != nil {
+		log.Errorln("relay.Reply:", err)
+		return
+	}
+
+	ack.PassThrough = self.ack.PassThrough
+
+	// 经过路由器的消息, 沿记录的路径返回
+	if self.ack.TTL > 0 && !self.ack.Backward {
+		ack.RouteKey = self.ack.RouteKey
+		ack.TTL = DefaultRouteTTL
+		ack.Route = self.ack.Route
+		ack.Backward = true
+	}
 
+	self.Ses.Send(ack)
 }
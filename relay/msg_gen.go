@@ -20,12 +20,18 @@ var (
 )
 
 type RelayACK struct {
-	Msg        []byte  `text:"-"` // 数据消息转换后传输bytes
-	MsgID      uint32  `text:"-"` // 消息ID
-	Bytes      []byte  `text:"-"` // 数据bytes
-	Int64      int64   // 透传int64
-	Int64Slice []int64 // 透传int64切片
-	Str        string
+	Msg         []byte  `text:"-"` // 数据消息转换后传输bytes
+	MsgID       uint32  `text:"-"` // 消息ID
+	Bytes       []byte  `text:"-"` // 数据bytes
+	Int64       int64   // 透传int64
+	Int64Slice  []int64 // 透传int64切片
+	Str         string
+	RouteKey    int64   // 路由键, 例如用户ID
+	TTL         int32   // 剩余转发次数, 大于0时表示需要经过路由器转发
+	Route       []int64 // 经过的路由器记录的来源跳ID, 回应时按此原路返回
+	Backward    bool    // 是否为沿原路返回的回应
+	Extra       []byte  `text:"-"` // 第二个起的消息, 依次为(消息ID, 长度, 数据)
+	PassThrough []byte  `text:"-"` // 带类型的透传值(消息, map[string]string)
 }
 
 func (self *RelayACK) String() string { return proto.CompactTextString(self) }
@@ -44,6 +50,18 @@ func (self *RelayACK) Size() (ret int) {
 
 	ret += proto.SizeString(5, self.Str)
 
+	ret += proto.SizeInt64(6, self.RouteKey)
+
+	ret += proto.SizeInt32(7, self.TTL)
+
+	ret += proto.SizeInt64Slice(8, self.Route)
+
+	ret += proto.SizeBool(9, self.Backward)
+
+	ret += proto.SizeBytes(10, self.Extra)
+
+	ret += proto.SizeBytes(11, self.PassThrough)
+
 	return
 }
 
@@ -61,6 +79,18 @@ func (self *RelayACK) Marshal(buffer *proto.Buffer) error {
 
 	proto.MarshalString(buffer, 5, self.Str)
 
+	proto.MarshalInt64(buffer, 6, self.RouteKey)
+
+	proto.MarshalInt32(buffer, 7, self.TTL)
+
+	proto.MarshalInt64Slice(buffer, 8, self.Route)
+
+	proto.MarshalBool(buffer, 9, self.Backward)
+
+	proto.MarshalBytes(buffer, 10, self.Extra)
+
+	proto.MarshalBytes(buffer, 11, self.PassThrough)
+
 	return nil
 }
 
@@ -78,6 +108,18 @@ func (self *RelayACK) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt prot
 		return proto.UnmarshalInt64Slice(buffer, wt, &self.Int64Slice)
 	case 5:
 		return proto.UnmarshalString(buffer, wt, &self.Str)
+	case 6:
+		return proto.UnmarshalInt64(buffer, wt, &self.RouteKey)
+	case 7:
+		return proto.UnmarshalInt32(buffer, wt, &self.TTL)
+	case 8:
+		return proto.UnmarshalInt64Slice(buffer, wt, &self.Route)
+	case 9:
+		return proto.UnmarshalBool(buffer, wt, &self.Backward)
+	case 10:
+		return proto.UnmarshalBytes(buffer, wt, &self.Extra)
+	case 11:
+		return proto.UnmarshalBytes(buffer, wt, &self.PassThrough)
 
 	}
 
@@ -0,0 +1,205 @@
+package relay
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/luis-quan/cellnet/codec"
+)
+
+var (
+	ErrMalformedPayload = errors.New("relay: malformed payload")
+)
+
+// 作为透传值的消息, 与普通消息(payload)区分
+type PassThroughMsg struct {
+	Msg interface{}
+}
+
+// 透传值类型
+const (
+	passThrough_Msg byte = iota + 1
+	passThrough_Map
+)
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendBytes(buf, data []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// 依次读取变长整数及数据
+type payloadReader struct {
+	buf []byte
+	err error
+}
+
+func (self *payloadReader) uvarint() uint64 {
+
+	if self.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(self.buf)
+	if n <= 0 {
+		self.err = ErrMalformedPayload
+		return 0
+	}
+
+	self.buf = self.buf[n:]
+	return v
+}
+
+func (self *payloadReader) bytes() []byte {
+
+	size := self.uvarint()
+
+	if self.err != nil {
+		return nil
+	}
+
+	if uint64(len(self.buf)) < size {
+		self.err = ErrMalformedPayload
+		return nil
+	}
+
+	data := self.buf[:size]
+	self.buf = self.buf[size:]
+	return data
+}
+
+func (self *payloadReader) byte() byte {
+
+	if self.err != nil {
+		return 0
+	}
+
+	if len(self.buf) == 0 {
+		self.err = ErrMalformedPayload
+		return 0
+	}
+
+	v := self.buf[0]
+	self.buf = self.buf[1:]
+	return v
+}
+
+func (self *payloadReader) done() bool {
+	return self.err != nil || len(self.buf) == 0
+}
+
+// 编码消息并追加为(消息ID, 长度, 数据)
+func appendMsg(buf []byte, msg interface{}) ([]byte, error) {
+
+	data, meta, err := codec.EncodeMessage(msg, nil)
+	if err != nil {
+		return buf, err
+	}
+
+	buf = appendUvarint(buf, uint64(meta.ID))
+	return appendBytes(buf, data), nil
+}
+
+func (self *payloadReader) msg() (interface{}, error) {
+
+	msgid := self.uvarint()
+	data := self.bytes()
+
+	if self.err != nil {
+		return nil, self.err
+	}
+
+	msg, _, err := codec.DecodeMessage(int(msgid), data)
+	return msg, err
+}
+
+func appendMap(buf []byte, m map[string]string) []byte {
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	buf = appendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendBytes(buf, []byte(k))
+		buf = appendBytes(buf, []byte(m[k]))
+	}
+
+	return buf
+}
+
+func (self *payloadReader) stringMap() map[string]string {
+
+	count := self.uvarint()
+
+	ret := make(map[string]string)
+	for i := uint64(0); i < count && self.err == nil; i++ {
+		k := self.bytes()
+		v := self.bytes()
+		ret[string(k)] = string(v)
+	}
+
+	return ret
+}
+
+// 解析第二个起的消息
+func decodeExtra(extra []byte) (ret []interface{}, err error) {
+
+	reader := payloadReader{buf: extra}
+
+	for !reader.done() {
+
+		msg, err := reader.msg()
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, msg)
+	}
+
+	return ret, reader.err
+}
+
+// 解析带类型的透传值
+func decodePassThrough(data []byte) (msgs []interface{}, m map[string]string, err error) {
+
+	reader := payloadReader{buf: data}
+
+	for !reader.done() {
+
+		switch reader.byte() {
+		case passThrough_Msg:
+
+			msg, err := reader.msg()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			msgs = append(msgs, msg)
+		case passThrough_Map:
+
+			for k, v := range reader.stringMap() {
+				if m == nil {
+					m = make(map[string]string)
+				}
+
+				m[k] = v
+			}
+		default:
+			if reader.err == nil {
+				reader.err = ErrMalformedPayload
+			}
+		}
+	}
+
+	return msgs, m, reader.err
+}
@@ -12,12 +12,24 @@ type PassthroughContent struct {
 	Str        string
 }
 
-// 处理入站的relay消息
+// 处理入站的relay消息, 设置路由器时, 需要路由的消息被转发后返回nil
 func ResoleveInboundEvent(inputEvent cellnet.Event) (ouputEvent cellnet.Event, handled bool, err error) {
 
 	switch relayMsg := inputEvent.Message().(type) {
+	case *cellnet.SessionClosed:
+
+		// 断开的会话不再作为下一跳
+		if router := sessionRouter(inputEvent.Session()); router != nil {
+			router.RemoveSession(inputEvent.Session())
+		}
+
 	case *RelayACK:
 
+		// 路由器转发的消息不再交给本地处理
+		if router := sessionRouter(inputEvent.Session()); router != nil && router.forward(inputEvent.Session(), relayMsg) {
+			return nil, true, nil
+		}
+
 		ev := &RecvMsgEvent{
 			Ses: inputEvent.Session(),
 			ack: relayMsg,
@@ -31,6 +43,18 @@ func ResoleveInboundEvent(inputEvent cellnet.Event) (ouputEvent cellnet.Event, h
 			}
 		}
 
+		if len(relayMsg.Extra) > 0 {
+			if ev.extra, err = decodeExtra(relayMsg.Extra); err != nil {
+				return
+			}
+		}
+
+		if len(relayMsg.PassThrough) > 0 {
+			if ev.passThroughMsgs, ev.passThroughMap, err = decodePassThrough(relayMsg.PassThrough); err != nil {
+				return
+			}
+		}
+
 		if msglog.IsMsgLogValid(int(relayMsg.MsgID)) {
 
 			peerInfo := inputEvent.Session().Peer().(cellnet.PeerProperty)
@@ -11,7 +11,7 @@ var (
 	ErrInvalidPeerSession = errors.New("Require valid cellnet.Session or cellnet.TCPConnector")
 )
 
-// payload: msg/bytes   passthrough: int64, []int64, string
+// payload: msg(可以多个)/bytes   passthrough: int64, []int64, string, map[string]string, PassThroughMsg
 func Relay(sesDetector interface{}, dataList ...interface{}) error {
 
 	ses, err := getSession(sesDetector)
@@ -20,6 +20,41 @@ func Relay(sesDetector interface{}, dataList ...interface{}) error {
 		return err
 	}
 
+	ack, err := buildACK(dataList)
+	if err != nil {
+		return err
+	}
+
+	ses.Send(ack)
+
+	return nil
+}
+
+// 发送需要路由的消息, 经过的路由器按routeKey查找下一跳, 回应沿原路返回
+// payload: msg(可以多个)/bytes   passthrough: int64, []int64, string, map[string]string, PassThroughMsg
+func RouteTo(sesDetector interface{}, routeKey int64, dataList ...interface{}) error {
+
+	ses, err := getSession(sesDetector)
+	if err != nil {
+		log.Errorln("relay.RouteTo:", err)
+		return err
+	}
+
+	ack, err := buildACK(dataList)
+	if err != nil {
+		return err
+	}
+
+	ack.RouteKey = routeKey
+	ack.TTL = DefaultRouteTTL
+
+	ses.Send(ack)
+
+	return nil
+}
+
+func buildACK(dataList []interface{}) (*RelayACK, error) {
+
 	var ack RelayACK
 
 	for _, payload := range dataList {
@@ -33,25 +68,42 @@ func Relay(sesDetector interface{}, dataList ...interface{}) error {
 			ack.Str = value
 		case []byte: // 作为payload
 			ack.Bytes = value
+		case map[string]string:
+			ack.PassThrough = append(ack.PassThrough, passThrough_Map)
+			ack.PassThrough = appendMap(ack.PassThrough, value)
+		case PassThroughMsg:
+			var err error
+			ack.PassThrough = append(ack.PassThrough, passThrough_Msg)
+			ack.PassThrough, err = appendMsg(ack.PassThrough, value.Msg)
+
+			if err != nil {
+				return nil, err
+			}
 		default:
+			var err error
+
+			// 第一个消息使用原有字段, 旧版本仍然可以识别
 			if ack.MsgID == 0 {
 				var meta *cellnet.MessageMeta
 				ack.Msg, meta, err = codec.EncodeMessage(payload, nil)
 
 				if err != nil {
-					return err
+					return nil, err
 				}
 
 				ack.MsgID = uint32(meta.ID)
 			} else {
-				panic("Multi message relay not support")
+				ack.Extra, err = appendMsg(ack.Extra, payload)
+
+				if err != nil {
+					return nil, err
+				}
 			}
 
 		}
 	}
-	ses.Send(&ack)
 
-	return nil
+	return &ack, nil
 }
 
 func getSession(sesDetector interface{}) (cellnet.Session, error) {
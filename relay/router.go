@@ -0,0 +1,338 @@
+package relay
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luis-quan/cellnet"
+)
+
+// RouteTo发出消息的默认最大转发次数
+const DefaultRouteTTL = 8
+
+var (
+	ErrRouteOverlap = errors.New("relay: route range overlaps existing route")
+)
+
+type routeEntry struct {
+	begin, end int64 // 路由键范围, 包含两端
+	ses        cellnet.Session
+}
+
+// 返回路径, 消息转发给ses时携带的路由记录
+type returnPath struct {
+	ses   cellnet.Session
+	route string
+}
+
+// 路由器, 网关按路由键范围登记下一跳会话, 收到需要路由的RelayACK时转发
+// 转发时记录来源会话的跳ID, 回应沿记录的路径返回
+type Router struct {
+	guard sync.RWMutex
+
+	// 按begin排序, 范围不重叠
+	routes []*routeEntry
+
+	hopSeq   int64
+	sesByHop map[int64]cellnet.Session
+	hopBySes map[cellnet.Session]int64
+
+	// 只接受从转发目标会话返回, 且路由记录一致的回应, 防止伪造跳ID向其他会话注入消息
+	returnPaths map[returnPath]int64 // 返回路径 -> 来源会话的跳ID
+	pathsByHop  map[int64]map[returnPath]struct{}
+	pathsBySes  map[cellnet.Session]map[returnPath]struct{}
+}
+
+// 登记路由, 路由键在[begin, end]范围内的消息转发到ses
+func (self *Router) AddRoute(begin, end int64, ses cellnet.Session) error {
+
+	if begin > end {
+		begin, end = end, begin
+	}
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	index := sort.Search(len(self.routes), func(i int) bool {
+		return self.routes[i].begin > begin
+	})
+
+	if index > 0 && self.routes[index-1].end >= begin {
+		return ErrRouteOverlap
+	}
+
+	if index < len(self.routes) && self.routes[index].begin <= end {
+		return ErrRouteOverlap
+	}
+
+	self.routes = append(self.routes, nil)
+	copy(self.routes[index+1:], self.routes[index:])
+	self.routes[index] = &routeEntry{begin, end, ses}
+
+	return nil
+}
+
+// 删除与begin开始的路由
+func (self *Router) RemoveRoute(begin int64) bool {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	for i, entry := range self.routes {
+		if entry.begin == begin {
+			self.routes = append(self.routes[:i], self.routes[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// 删除会话相关的所有路由及跳记录, 会话断开时自动调用
+func (self *Router) RemoveSession(ses cellnet.Session) {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	routes := self.routes[:0]
+	for _, entry := range self.routes {
+		if entry.ses != ses {
+			routes = append(routes, entry)
+		}
+	}
+
+	for i := len(routes); i < len(self.routes); i++ {
+		self.routes[i] = nil
+	}
+
+	self.routes = routes
+
+	if hop, ok := self.hopBySes[ses]; ok {
+		delete(self.hopBySes, ses)
+		delete(self.sesByHop, hop)
+
+		for path := range self.pathsByHop[hop] {
+			self.removePath(path)
+		}
+	}
+
+	for path := range self.pathsBySes[ses] {
+		self.removePath(path)
+	}
+}
+
+// 调用时需要加锁
+func (self *Router) removePath(path returnPath) {
+
+	hop, ok := self.returnPaths[path]
+	if !ok {
+		return
+	}
+
+	delete(self.returnPaths, path)
+
+	if paths := self.pathsByHop[hop]; paths != nil {
+		delete(paths, path)
+		if len(paths) == 0 {
+			delete(self.pathsByHop, hop)
+		}
+	}
+
+	if paths := self.pathsBySes[path.ses]; paths != nil {
+		delete(paths, path)
+		if len(paths) == 0 {
+			delete(self.pathsBySes, path.ses)
+		}
+	}
+}
+
+// 查找路由键对应的下一跳会话
+func (self *Router) Lookup(routeKey int64) cellnet.Session {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	index := sort.Search(len(self.routes), func(i int) bool {
+		return self.routes[i].begin > routeKey
+	})
+
+	if index > 0 && self.routes[index-1].end >= routeKey {
+		return self.routes[index-1].ses
+	}
+
+	return nil
+}
+
+// 获取会话的跳ID, 没有时分配
+func (self *Router) hopOf(ses cellnet.Session) int64 {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	if hop, ok := self.hopBySes[ses]; ok {
+		return hop
+	}
+
+	self.hopSeq++
+	self.hopBySes[ses] = self.hopSeq
+	self.sesByHop[self.hopSeq] = ses
+
+	return self.hopSeq
+}
+
+func routeString(route []int64) string {
+
+	var sb strings.Builder
+	for i, hop := range route {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+
+		sb.WriteString(strconv.FormatInt(hop, 10))
+	}
+
+	return sb.String()
+}
+
+// 消息带着route转发给next, 记录返回路径, hop为来源会话的跳ID
+func (self *Router) addReturnPath(next cellnet.Session, route []int64, hop int64) {
+
+	path := returnPath{next, routeString(route)}
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	// 来源会话已经断开
+	if _, ok := self.sesByHop[hop]; !ok {
+		return
+	}
+
+	if _, ok := self.returnPaths[path]; ok {
+		return
+	}
+
+	self.returnPaths[path] = hop
+
+	byHop := self.pathsByHop[hop]
+	if byHop == nil {
+		byHop = make(map[returnPath]struct{})
+		self.pathsByHop[hop] = byHop
+	}
+
+	byHop[path] = struct{}{}
+
+	bySes := self.pathsBySes[next]
+	if bySes == nil {
+		bySes = make(map[returnPath]struct{})
+		self.pathsBySes[next] = bySes
+	}
+
+	bySes[path] = struct{}{}
+}
+
+// 从ses收到的回应, 路由记录与转发给ses时一致时, 返回下一跳会话
+func (self *Router) returnSession(ses cellnet.Session, route []int64) cellnet.Session {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	if _, ok := self.returnPaths[returnPath{ses, routeString(route)}]; !ok {
+		return nil
+	}
+
+	return self.sesByHop[route[len(route)-1]]
+}
+
+// 转发需要路由的消息, 返回false时表示由本地处理
+func (self *Router) forward(ses cellnet.Session, ack *RelayACK) bool {
+
+	if ack.TTL <= 0 {
+		return false
+	}
+
+	// 回应, 沿原路返回
+	if ack.Backward {
+
+		// 已经回到发起方
+		if len(ack.Route) == 0 {
+			return false
+		}
+
+		next := self.returnSession(ses, ack.Route)
+		if next == nil {
+			log.Warnf("relay return path not found, session: %d, route: %v, drop", ses.ID(), ack.Route)
+			return true
+		}
+
+		fwd := *ack
+		fwd.Route = ack.Route[:len(ack.Route)-1]
+		next.Send(&fwd)
+
+		return true
+	}
+
+	next := self.Lookup(ack.RouteKey)
+
+	// 没有路由或者路由回来源, 本地处理
+	if next == nil || next == ses {
+		return false
+	}
+
+	if ack.TTL <= 1 {
+		log.Warnf("relay route ttl exceeded, key: %d, drop", ack.RouteKey)
+		return true
+	}
+
+	hop := self.hopOf(ses)
+
+	fwd := *ack
+	fwd.TTL--
+	fwd.Route = append(append([]int64(nil), ack.Route...), hop)
+
+	self.addReturnPath(next, fwd.Route, hop)
+
+	next.Send(&fwd)
+
+	return true
+}
+
+func NewRouter() *Router {
+
+	return &Router{
+		sesByHop:    make(map[int64]cellnet.Session),
+		hopBySes:    make(map[cellnet.Session]int64),
+		returnPaths: make(map[returnPath]int64),
+		pathsByHop:  make(map[int64]map[returnPath]struct{}),
+		pathsBySes:  make(map[cellnet.Session]map[returnPath]struct{}),
+	}
+}
+
+type routerKey struct{}
+
+// 为Peer绑定路由器, Peer的会话收到需要路由的消息时, 先尝试转发
+// 网关的前端及后端Peer需要绑定同一个路由器, 回应才能沿原路返回
+func BindRouter(p cellnet.Peer, r *Router) {
+	p.(cellnet.ContextSet).SetContext(routerKey{}, r)
+}
+
+// 获取会话所在Peer绑定的路由器
+func sessionRouter(ses cellnet.Session) *Router {
+
+	if ses == nil {
+		return nil
+	}
+
+	ctxSet, ok := ses.Peer().(cellnet.ContextSet)
+	if !ok {
+		return nil
+	}
+
+	if raw, ok := ctxSet.GetContext(routerKey{}); ok {
+		return raw.(*Router)
+	}
+
+	return nil
+}
@@ -0,0 +1,7 @@
+package reliable
+
+import (
+	"github.com/davyxu/golog"
+)
+
+var log = golog.New("reliable")
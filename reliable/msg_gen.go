@@ -0,0 +1,122 @@
+// Generated by github.com/luis-quan/protoplus
+// DO NOT EDIT!
+package reliable
+
+import (
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
+	_ "github.com/luis-quan/cellnet/codec/protoplus"
+	"github.com/davyxu/protoplus/proto"
+	"reflect"
+	"unsafe"
+)
+
+var (
+	_ *proto.Buffer
+	_ codec.CodecRecycler
+	_ cellnet.Session
+	_ reflect.Type
+	_ unsafe.Pointer
+)
+
+type ReliableFrame struct {
+	StreamID int64  // 发送方的流ID, 重连后保持不变
+	Seq      uint64 // 发送方向上单调递增的序号
+	MsgID    uint32
+	Data     []byte
+}
+
+func (self *ReliableFrame) String() string { return proto.CompactTextString(self) }
+
+func (self *ReliableFrame) Size() (ret int) {
+
+	ret += proto.SizeInt64(0, self.StreamID)
+
+	ret += proto.SizeUInt64(1, self.Seq)
+
+	ret += proto.SizeUInt32(2, self.MsgID)
+
+	ret += proto.SizeBytes(3, self.Data)
+
+	return
+}
+
+func (self *ReliableFrame) Marshal(buffer *proto.Buffer) error {
+
+	proto.MarshalInt64(buffer, 0, self.StreamID)
+
+	proto.MarshalUInt64(buffer, 1, self.Seq)
+
+	proto.MarshalUInt32(buffer, 2, self.MsgID)
+
+	proto.MarshalBytes(buffer, 3, self.Data)
+
+	return nil
+}
+
+func (self *ReliableFrame) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt proto.WireType) error {
+	switch fieldIndex {
+	case 0:
+		return proto.UnmarshalInt64(buffer, wt, &self.StreamID)
+	case 1:
+		return proto.UnmarshalUInt64(buffer, wt, &self.Seq)
+	case 2:
+		return proto.UnmarshalUInt32(buffer, wt, &self.MsgID)
+	case 3:
+		return proto.UnmarshalBytes(buffer, wt, &self.Data)
+
+	}
+
+	return proto.ErrUnknownField
+}
+
+type ReliableACK struct {
+	StreamID int64
+	Seq      uint64 // 累计确认, 此序号及之前的消息都已经收到
+}
+
+func (self *ReliableACK) String() string { return proto.CompactTextString(self) }
+
+func (self *ReliableACK) Size() (ret int) {
+
+	ret += proto.SizeInt64(0, self.StreamID)
+
+	ret += proto.SizeUInt64(1, self.Seq)
+
+	return
+}
+
+func (self *ReliableACK) Marshal(buffer *proto.Buffer) error {
+
+	proto.MarshalInt64(buffer, 0, self.StreamID)
+
+	proto.MarshalUInt64(buffer, 1, self.Seq)
+
+	return nil
+}
+
+func (self *ReliableACK) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt proto.WireType) error {
+	switch fieldIndex {
+	case 0:
+		return proto.UnmarshalInt64(buffer, wt, &self.StreamID)
+	case 1:
+		return proto.UnmarshalUInt64(buffer, wt, &self.Seq)
+
+	}
+
+	return proto.ErrUnknownField
+}
+
+func init() {
+
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("protoplus"),
+		Type:  reflect.TypeOf((*ReliableFrame)(nil)).Elem(),
+		ID:    44825,
+	})
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("protoplus"),
+		Type:  reflect.TypeOf((*ReliableACK)(nil)).Elem(),
+		ID:    33149,
+	})
+}
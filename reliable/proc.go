@@ -0,0 +1,98 @@
+package reliable
+
+import (
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
+)
+
+// 处理入站事件, 解开可靠消息并去重, 重复的消息及确认消息返回nil
+func ResolveInboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event, err error) {
+
+	ses := inputEvent.Session()
+
+	switch msg := inputEvent.Message().(type) {
+	case *ReliableFrame:
+
+		// 只有启用了可靠传输的Peer记录接收状态
+		ps := peerStateOf(ses.Peer())
+		if ps == nil {
+			return nil, ErrNotEnabled
+		}
+
+		// 解码成功后才记录序号, 解码失败的消息不确认
+		var userMsg interface{}
+		userMsg, _, err = codec.DecodeMessage(int(msg.MsgID), msg.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		var fresh bool
+		fresh, err = ps.accept(ses, msg)
+		if err != nil || !fresh {
+			return nil, err
+		}
+
+		return &cellnet.RecvMsgEvent{Ses: ses, Id: int(msg.MsgID), Msg: userMsg}, nil
+
+	case *ReliableACK:
+
+		if s := senderOf(ses, false); s != nil && s.streamID == msg.StreamID {
+			s.trim(msg.Seq)
+		}
+
+		return nil, nil
+
+	case *cellnet.SessionClosed:
+
+		if ps := peerStateOf(ses.Peer()); ps != nil {
+			ps.removeSession(ses)
+		}
+
+	case *cellnet.SessionConnected:
+
+		// 连接器重连后会话不变, 重发上次连接中没有确认的消息
+		if s := senderOf(ses, false); s != nil {
+			s.resend(ses)
+		}
+	}
+
+	return inputEvent, nil
+}
+
+// 处理出站事件, 启用了可靠传输的Peer发送可靠消息时, 分配序号并保留到对方确认
+func ResolveOutboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event, err error) {
+
+	msg := inputEvent.Message()
+
+	switch msg.(type) {
+	case *ReliableFrame, *ReliableACK, *cellnet.RawPacket:
+		return inputEvent, nil
+	}
+
+	ses := inputEvent.Session()
+
+	ps := peerStateOf(ses.Peer())
+	if ps == nil || !IsReliable(cellnet.MessageMetaByMsg(msg)) {
+		return inputEvent, nil
+	}
+
+	s := senderOf(ses, true)
+	if s == nil {
+		return inputEvent, nil
+	}
+
+	data, meta, err := codec.EncodeMessage(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := s.push(meta.ID, data, ps.opt.MaxUnacked)
+
+	// 对方长时间没有确认, 不再保证送达, 关闭会话通知双方
+	if frame == nil {
+		cellnet.CloseSession(ses, cellnet.CloseReason_UnackedOverflow, 0)
+		return nil, ErrUnackedOverflow
+	}
+
+	return &cellnet.SendMsgEvent{Ses: ses, Msg: frame}, nil
+}
@@ -0,0 +1,422 @@
+package reliable
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/timer"
+)
+
+// MessageMeta上标记可靠消息的上下文名
+const MetaContextKey = "reliable"
+
+const (
+	DefaultAckInterval = time.Millisecond * 200
+	DefaultMaxUnacked  = 4096
+	DefaultStreamIdle  = time.Minute * 10
+
+	// 接收方等待缺失序号时, 最多记录的乱序序号数量
+	maxAheadSeq = 1024
+
+	// 每个会话上最多记录的对方流数量, 正常情况下对方每个会话只有一个流
+	maxSessionStreams = 16
+)
+
+var (
+	ErrNotEnabled      = errors.New("reliable: frame received on peer without reliable enabled")
+	ErrTooManyStreams  = errors.New("reliable: too many streams on session")
+	ErrUnackedOverflow = errors.New("reliable: too many unacked messages")
+)
+
+// 可靠传输参数, 为0的字段使用默认值
+type Option struct {
+	AckInterval time.Duration // 收到消息后, 延迟发送累计确认的时间
+	MaxUnacked  int           // 每个会话保留等待确认的最大消息数量, 超过时以CloseReason_UnackedOverflow关闭会话
+	StreamIdle  time.Duration // 对方的流超过此时间没有消息时, 丢弃接收状态, 之后无法去重
+}
+
+// 将消息标记为可靠消息, 启用了可靠传输的Peer发送时分配序号并保留到对方确认
+func SetReliable(meta *cellnet.MessageMeta, reliable bool) {
+	meta.SetContext(MetaContextKey, reliable)
+}
+
+func IsReliable(meta *cellnet.MessageMeta) bool {
+
+	if meta == nil {
+		return false
+	}
+
+	if v, ok := meta.GetContext(MetaContextKey); ok {
+		reliable, _ := v.(bool)
+		return reliable
+	}
+
+	return false
+}
+
+type (
+	peerKey   struct{}
+	senderKey struct{}
+)
+
+var peerGuard sync.Mutex
+
+// 为Peer启用可靠传输, 在Start之前调用
+// 连接端的会话在重连后保持不变, 重连时重发未确认的消息; 接受端每个连接是新的会话, 需要配合tcp.ltv.resume的逻辑会话才能跨重连保留
+func Enable(p cellnet.Peer, opt Option) {
+
+	if opt.AckInterval <= 0 {
+		opt.AckInterval = DefaultAckInterval
+	}
+
+	if opt.MaxUnacked <= 0 {
+		opt.MaxUnacked = DefaultMaxUnacked
+	}
+
+	if opt.StreamIdle <= 0 {
+		opt.StreamIdle = DefaultStreamIdle
+	}
+
+	peerGuard.Lock()
+	p.(cellnet.ContextSet).SetContext(peerKey{}, newPeerState(opt))
+	peerGuard.Unlock()
+}
+
+// 会话上等待对方确认的消息数量
+func Unacked(ses cellnet.Session) int {
+
+	if s := senderOf(ses, false); s != nil {
+		s.guard.Lock()
+		defer s.guard.Unlock()
+		return len(s.unacked)
+	}
+
+	return 0
+}
+
+// 接收状态中引用的会话数量, 会话关闭后不再引用
+func TrackedSessions(p cellnet.Peer) int {
+
+	if ps := peerStateOf(p); ps != nil {
+		ps.guard.Lock()
+		defer ps.guard.Unlock()
+		return len(ps.countBySes)
+	}
+
+	return 0
+}
+
+// Peer的可靠传输状态, 没有启用时返回nil
+func peerStateOf(p cellnet.Peer) *peerState {
+
+	ctxSet, ok := p.(cellnet.ContextSet)
+	if !ok {
+		return nil
+	}
+
+	peerGuard.Lock()
+	defer peerGuard.Unlock()
+
+	if raw, ok := ctxSet.GetContext(peerKey{}); ok {
+		return raw.(*peerState)
+	}
+
+	return nil
+}
+
+// 发送方向的状态, 保存在会话上
+type sender struct {
+	guard    sync.Mutex
+	streamID int64
+	seq      uint64
+	unacked  []*ReliableFrame
+}
+
+// 等待确认的消息已满时不保存, 返回nil, 并重置发送状态, 之后的消息使用新的流
+func (self *sender) push(msgID int, data []byte, maxUnacked int) *ReliableFrame {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	if len(self.unacked) >= maxUnacked {
+		log.Warnf("reliable unacked overflow, stream: %d, unacked: %d", self.streamID, len(self.unacked))
+		self.streamID = newStreamID()
+		self.seq = 0
+		self.unacked = nil
+		return nil
+	}
+
+	self.seq++
+
+	frame := &ReliableFrame{
+		StreamID: self.streamID,
+		Seq:      self.seq,
+		MsgID:    uint32(msgID),
+		Data:     data,
+	}
+
+	self.unacked = append(self.unacked, frame)
+
+	return frame
+}
+
+func (self *sender) trim(seq uint64) {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	var index int
+	for index < len(self.unacked) && self.unacked[index].Seq <= seq {
+		self.unacked[index] = nil
+		index++
+	}
+
+	self.unacked = self.unacked[index:]
+}
+
+// 重连后重发所有未确认的消息
+func (self *sender) resend(ses cellnet.Session) {
+
+	self.guard.Lock()
+	list := make([]*ReliableFrame, len(self.unacked))
+	copy(list, self.unacked)
+	self.guard.Unlock()
+
+	for _, frame := range list {
+		ses.Send(frame)
+	}
+}
+
+func senderOf(ses cellnet.Session, create bool) *sender {
+
+	if ses == nil {
+		return nil
+	}
+
+	ctxSet, ok := ses.(cellnet.ContextSet)
+	if !ok {
+		return nil
+	}
+
+	peerGuard.Lock()
+	defer peerGuard.Unlock()
+
+	if raw, ok := ctxSet.GetContext(senderKey{}); ok {
+		return raw.(*sender)
+	}
+
+	if !create {
+		return nil
+	}
+
+	s := &sender{streamID: newStreamID()}
+	ctxSet.SetContext(senderKey{}, s)
+
+	return s
+}
+
+// 接收方向的状态, 按对方的流ID保存在Peer上, 接受端重连后仍然可以去重
+type recvStream struct {
+	streamID int64
+	ses      cellnet.Session // 最近收到消息的会话, 确认发往此会话
+
+	lastSeq uint64          // 连续收到的最大序号
+	ahead   map[uint64]bool // 重连时新旧消息交错, 先于缺失序号收到的消息
+
+	needAck    bool
+	ackTimer   timer.AfterStopper
+	lastActive time.Time
+}
+
+// 是否为第一次收到的序号
+func (self *recvStream) accept(seq uint64) bool {
+
+	if seq <= self.lastSeq || self.ahead[seq] {
+		return false
+	}
+
+	if seq == self.lastSeq+1 {
+		self.lastSeq = seq
+		self.advance()
+		return true
+	}
+
+	if self.ahead == nil {
+		self.ahead = make(map[uint64]bool)
+	}
+
+	self.ahead[seq] = true
+
+	// 缺失的消息已经被对方丢弃, 不再等待
+	if len(self.ahead) > maxAheadSeq {
+
+		var minSeq uint64
+		for s := range self.ahead {
+			if minSeq == 0 || s < minSeq {
+				minSeq = s
+			}
+		}
+
+		delete(self.ahead, minSeq)
+		self.lastSeq = minSeq
+		self.advance()
+	}
+
+	return true
+}
+
+func (self *recvStream) advance() {
+	for self.ahead[self.lastSeq+1] {
+		delete(self.ahead, self.lastSeq+1)
+		self.lastSeq++
+	}
+}
+
+type peerState struct {
+	opt Option
+
+	guard    sync.Mutex
+	streamBy map[int64]*recvStream
+
+	// 每个会话上记录的流数量, 流的会话为最近收到消息的会话
+	countBySes map[cellnet.Session]int
+}
+
+// 记录收到的消息, 重复的消息返回false
+func (self *peerState) accept(ses cellnet.Session, frame *ReliableFrame) (bool, error) {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	now := timer.Now()
+
+	stream := self.streamBy[frame.StreamID]
+	if stream == nil {
+
+		self.purge(now)
+
+		if self.countBySes[ses] >= maxSessionStreams {
+			return false, ErrTooManyStreams
+		}
+
+		// 对方的第一条消息可能不是1, 例如接收方重启时对方仍保留着未确认的消息
+		stream = &recvStream{
+			streamID: frame.StreamID,
+			lastSeq:  frame.Seq - 1,
+		}
+
+		self.streamBy[frame.StreamID] = stream
+	}
+
+	// 对方重连后, 流转到新的会话
+	if stream.ses != ses {
+
+		if stream.ses != nil {
+			self.countSession(stream.ses, -1)
+		}
+
+		self.countSession(ses, 1)
+		stream.ses = ses
+	}
+
+	stream.lastActive = now
+
+	// 重复的消息说明对方没有收到确认, 仍然需要回应
+	stream.needAck = true
+
+	if stream.ackTimer == nil {
+		stream.ackTimer = timer.CurrentClock().AfterFunc(self.opt.AckInterval, func() {
+			self.flushAck(stream)
+		})
+	}
+
+	return stream.accept(frame.Seq), nil
+}
+
+// 调用时需要加锁
+func (self *peerState) countSession(ses cellnet.Session, delta int) {
+
+	if count := self.countBySes[ses] + delta; count > 0 {
+		self.countBySes[ses] = count
+	} else {
+		delete(self.countBySes, ses)
+	}
+}
+
+func (self *peerState) flushAck(stream *recvStream) {
+
+	self.guard.Lock()
+
+	stream.ackTimer = nil
+
+	if !stream.needAck {
+		self.guard.Unlock()
+		return
+	}
+
+	stream.needAck = false
+	ses := stream.ses
+	ack := &ReliableACK{StreamID: stream.streamID, Seq: stream.lastSeq}
+
+	self.guard.Unlock()
+
+	// 会话已经关闭, 对方重连后重发时再确认
+	if ses != nil {
+		ses.Send(ack)
+	}
+}
+
+// 会话关闭后, 流保留用于重连后去重, 但不再引用会话
+func (self *peerState) removeSession(ses cellnet.Session) {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	if _, ok := self.countBySes[ses]; !ok {
+		return
+	}
+
+	for _, stream := range self.streamBy {
+		if stream.ses == ses {
+			stream.ses = nil
+		}
+	}
+
+	delete(self.countBySes, ses)
+}
+
+// 丢弃长时间没有消息的流, 调用时需要加锁
+func (self *peerState) purge(now time.Time) {
+
+	for id, stream := range self.streamBy {
+		if stream.ackTimer == nil && now.Sub(stream.lastActive) > self.opt.StreamIdle {
+			delete(self.streamBy, id)
+
+			if stream.ses != nil {
+				self.countSession(stream.ses, -1)
+			}
+		}
+	}
+}
+
+func newPeerState(opt Option) *peerState {
+	return &peerState{
+		opt:        opt,
+		streamBy:   make(map[int64]*recvStream),
+		countBySes: make(map[cellnet.Session]int),
+	}
+}
+
+func newStreamID() int64 {
+
+	var data [8]byte
+	if _, err := rand.Read(data[:]); err != nil {
+		panic(err)
+	}
+
+	return int64(binary.LittleEndian.Uint64(data[:]) >> 1)
+}
@@ -0,0 +1,7 @@
+package resume
+
+import (
+	"github.com/davyxu/golog"
+)
+
+var log = golog.New("resume")
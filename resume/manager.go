@@ -0,0 +1,293 @@
+package resume
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/peer"
+)
+
+// 恢复参数, 为0的字段使用默认值
+type Option struct {
+	GracePeriod time.Duration // 连接断开后等待恢复的时间
+	MaxReplay   int           // 等待确认的最大消息数量, 超过时丢弃最早的消息, 对方未收到这些消息时无法恢复; 断开期间缓存的消息超过时放弃逻辑会话
+	AckInterval int           // 每收到多少消息回复一次确认
+}
+
+type (
+	optionKey  struct{}
+	managerKey struct{}
+	sessionKey struct{}
+)
+
+var managerGuard sync.Mutex
+
+// 设置Peer的恢复参数, 在Start之前调用
+func SetOption(p cellnet.Peer, opt Option) {
+	p.(cellnet.ContextSet).SetContext(optionKey{}, opt)
+}
+
+func optionOf(p cellnet.Peer) (opt Option) {
+
+	if ctxSet, ok := p.(cellnet.ContextSet); ok {
+		ctxSet.FetchContext(optionKey{}, &opt)
+	}
+
+	if opt.GracePeriod <= 0 {
+		opt.GracePeriod = DefaultGracePeriod
+	}
+
+	if opt.MaxReplay <= 0 {
+		opt.MaxReplay = DefaultMaxReplay
+	}
+
+	if opt.AckInterval <= 0 {
+		opt.AckInterval = DefaultAckInterval
+	}
+
+	return
+}
+
+// 连接对应的逻辑会话, 没有时返回ses本身
+func SessionOf(ses cellnet.Session) cellnet.Session {
+
+	if logical := sessionOf(ses); logical != nil {
+		return logical
+	}
+
+	return ses
+}
+
+func sessionOf(ses cellnet.Session) *Session {
+
+	if ses == nil {
+		return nil
+	}
+
+	if logical, ok := ses.(*Session); ok {
+		return logical
+	}
+
+	ctxSet, ok := ses.(cellnet.ContextSet)
+	if !ok {
+		return nil
+	}
+
+	if raw, ok := ctxSet.GetContext(sessionKey{}); ok {
+		return raw.(*Session)
+	}
+
+	return nil
+}
+
+// 接受端的逻辑会话管理, 按逻辑会话ID访问
+type Manager struct {
+	guard   sync.RWMutex
+	byID    map[int64]*Session
+	byToken map[string]*Session
+
+	// 用户键索引, 与byID使用相同的锁
+	sesByKey map[interface{}]*Session
+	keyByID  map[int64]interface{}
+
+	groups *peer.SessionGroups
+}
+
+func (self *Manager) GetSession(id int64) cellnet.Session {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	if ses, ok := self.byID[id]; ok {
+		return ses
+	}
+
+	return nil
+}
+
+func (self *Manager) VisitSession(callback func(cellnet.Session) bool) {
+
+	self.guard.RLock()
+	list := make([]*Session, 0, len(self.byID))
+	for _, ses := range self.byID {
+		list = append(list, ses)
+	}
+	self.guard.RUnlock()
+
+	for _, ses := range list {
+		if !callback(ses) {
+			break
+		}
+	}
+}
+
+func (self *Manager) SessionCount() int {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	return len(self.byID)
+}
+
+func (self *Manager) CloseAllSession() {
+
+	self.VisitSession(func(ses cellnet.Session) bool {
+		ses.Close()
+		return true
+	})
+}
+
+// 逻辑会话的分组, 逻辑会话结束时自动离开所有分组
+func (self *Manager) Groups() *peer.SessionGroups {
+	return self.groups
+}
+
+// 为逻辑会话建立用户键索引, 实现peer.KeyedSessionAccessor
+func (self *Manager) SetKey(ses cellnet.Session, key interface{}) error {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	logical, ok := ses.(*Session)
+	if !ok || self.byID[ses.ID()] != logical {
+		return peer.ErrSessionNotManaged
+	}
+
+	if exists, ok := self.sesByKey[key]; ok {
+		if exists == logical {
+			return nil
+		}
+
+		return peer.ErrSessionKeyInUse
+	}
+
+	if oldKey, ok := self.keyByID[logical.id]; ok {
+		delete(self.sesByKey, oldKey)
+	}
+
+	self.sesByKey[key] = logical
+	self.keyByID[logical.id] = key
+
+	return nil
+}
+
+func (self *Manager) RemoveKey(ses cellnet.Session) {
+
+	self.guard.Lock()
+	self.removeKey(ses.ID())
+	self.guard.Unlock()
+}
+
+func (self *Manager) SessionByKey(key interface{}) cellnet.Session {
+
+	self.guard.RLock()
+	defer self.guard.RUnlock()
+
+	if ses, ok := self.sesByKey[key]; ok {
+		return ses
+	}
+
+	return nil
+}
+
+// 调用时需要加锁
+func (self *Manager) removeKey(id int64) {
+
+	if key, ok := self.keyByID[id]; ok {
+		delete(self.sesByKey, key)
+		delete(self.keyByID, id)
+	}
+}
+
+func (self *Manager) add(ses *Session) {
+
+	self.guard.Lock()
+	self.byID[ses.id] = ses
+	self.byToken[ses.token] = ses
+	self.guard.Unlock()
+}
+
+func (self *Manager) remove(ses *Session) {
+
+	self.guard.Lock()
+	if self.byID[ses.id] == ses {
+		delete(self.byID, ses.id)
+		delete(self.byToken, ses.token)
+		self.removeKey(ses.id)
+	}
+	self.guard.Unlock()
+
+	self.groups.LeaveAll(ses)
+}
+
+// 处理连接上的恢复请求, 新建逻辑会话时返回SessionAccepted事件
+func (self *Manager) onRequest(phys cellnet.Session, msg *ResumeREQ) cellnet.Event {
+
+	var logical *Session
+
+	if msg.Token != "" {
+		self.guard.RLock()
+		logical = self.byToken[msg.Token]
+		self.guard.RUnlock()
+	}
+
+	if logical != nil {
+
+		if logical.resume(phys, msg.RecvSeq) {
+			return nil
+		}
+
+		log.Warnf("session resume failed, sesid: %d, recvseq: %d", logical.id, msg.RecvSeq)
+
+		// 对方缺失的消息已经被丢弃, 只能结束
+		logical.abandon(&cellnet.SessionClosed{Reason: cellnet.CloseReason_IO})
+	}
+
+	logical = newSession(phys.Peer(), phys.ID(), newToken(), self)
+	self.add(logical)
+
+	logical.guard.Lock()
+	logical.attach(phys, 0, logical.makeACK(ResumeResult_New))
+	logical.guard.Unlock()
+
+	return &cellnet.RecvMsgEvent{Ses: logical, Msg: &cellnet.SessionAccepted{}}
+}
+
+// 获取接受端的逻辑会话管理
+func ManagerOf(p cellnet.Peer) *Manager {
+
+	ctxSet := p.(cellnet.ContextSet)
+
+	managerGuard.Lock()
+	defer managerGuard.Unlock()
+
+	if raw, ok := ctxSet.GetContext(managerKey{}); ok {
+		return raw.(*Manager)
+	}
+
+	mgr := &Manager{
+		byID:     make(map[int64]*Session),
+		byToken:  make(map[string]*Session),
+		sesByKey: make(map[interface{}]*Session),
+		keyByID:  make(map[int64]interface{}),
+	}
+
+	mgr.groups = peer.NewSessionGroups(mgr)
+
+	ctxSet.SetContext(managerKey{}, mgr)
+
+	return mgr
+}
+
+func newToken() string {
+
+	var data [16]byte
+	if _, err := rand.Read(data[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(data[:])
+}
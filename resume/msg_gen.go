@@ -0,0 +1,164 @@
+// Generated by github.com/luis-quan/protoplus
+// DO NOT EDIT!
+package resume
+
+import (
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
+	_ "github.com/luis-quan/cellnet/codec/protoplus"
+	"github.com/davyxu/protoplus/proto"
+	"reflect"
+	"unsafe"
+)
+
+var (
+	_ *proto.Buffer
+	_ codec.CodecRecycler
+	_ cellnet.Session
+	_ reflect.Type
+	_ unsafe.Pointer
+)
+
+type ResumeREQ struct {
+	Token   string // 上次分配的令牌, 空表示新会话
+	RecvSeq uint64 // 已经收到的消息数量
+}
+
+func (self *ResumeREQ) String() string { return proto.CompactTextString(self) }
+
+func (self *ResumeREQ) Size() (ret int) {
+
+	ret += proto.SizeString(0, self.Token)
+
+	ret += proto.SizeUInt64(1, self.RecvSeq)
+
+	return
+}
+
+func (self *ResumeREQ) Marshal(buffer *proto.Buffer) error {
+
+	proto.MarshalString(buffer, 0, self.Token)
+
+	proto.MarshalUInt64(buffer, 1, self.RecvSeq)
+
+	return nil
+}
+
+func (self *ResumeREQ) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt proto.WireType) error {
+	switch fieldIndex {
+	case 0:
+		return proto.UnmarshalString(buffer, wt, &self.Token)
+	case 1:
+		return proto.UnmarshalUInt64(buffer, wt, &self.RecvSeq)
+
+	}
+
+	return proto.ErrUnknownField
+}
+
+type ResumeACK struct {
+	Token     string
+	SessionID int64  // 逻辑会话ID
+	RecvSeq   uint64 // 已经收到的消息数量
+	Result    int32
+}
+
+func (self *ResumeACK) String() string { return proto.CompactTextString(self) }
+
+func (self *ResumeACK) Size() (ret int) {
+
+	ret += proto.SizeString(0, self.Token)
+
+	ret += proto.SizeInt64(1, self.SessionID)
+
+	ret += proto.SizeUInt64(2, self.RecvSeq)
+
+	ret += proto.SizeInt32(3, self.Result)
+
+	return
+}
+
+func (self *ResumeACK) Marshal(buffer *proto.Buffer) error {
+
+	proto.MarshalString(buffer, 0, self.Token)
+
+	proto.MarshalInt64(buffer, 1, self.SessionID)
+
+	proto.MarshalUInt64(buffer, 2, self.RecvSeq)
+
+	proto.MarshalInt32(buffer, 3, self.Result)
+
+	return nil
+}
+
+func (self *ResumeACK) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt proto.WireType) error {
+	switch fieldIndex {
+	case 0:
+		return proto.UnmarshalString(buffer, wt, &self.Token)
+	case 1:
+		return proto.UnmarshalInt64(buffer, wt, &self.SessionID)
+	case 2:
+		return proto.UnmarshalUInt64(buffer, wt, &self.RecvSeq)
+	case 3:
+		return proto.UnmarshalInt32(buffer, wt, &self.Result)
+
+	}
+
+	return proto.ErrUnknownField
+}
+
+type ResumeSeqACK struct {
+	RecvSeq uint64 // 已经收到的消息数量
+	Close   bool   // 对方主动关闭, 不再等待恢复
+}
+
+func (self *ResumeSeqACK) String() string { return proto.CompactTextString(self) }
+
+func (self *ResumeSeqACK) Size() (ret int) {
+
+	ret += proto.SizeUInt64(0, self.RecvSeq)
+
+	ret += proto.SizeBool(1, self.Close)
+
+	return
+}
+
+func (self *ResumeSeqACK) Marshal(buffer *proto.Buffer) error {
+
+	proto.MarshalUInt64(buffer, 0, self.RecvSeq)
+
+	proto.MarshalBool(buffer, 1, self.Close)
+
+	return nil
+}
+
+func (self *ResumeSeqACK) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt proto.WireType) error {
+	switch fieldIndex {
+	case 0:
+		return proto.UnmarshalUInt64(buffer, wt, &self.RecvSeq)
+	case 1:
+		return proto.UnmarshalBool(buffer, wt, &self.Close)
+
+	}
+
+	return proto.ErrUnknownField
+}
+
+func init() {
+
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("protoplus"),
+		Type:  reflect.TypeOf((*ResumeREQ)(nil)).Elem(),
+		ID:    3608,
+	})
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("protoplus"),
+		Type:  reflect.TypeOf((*ResumeACK)(nil)).Elem(),
+		ID:    30975,
+	})
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("protoplus"),
+		Type:  reflect.TypeOf((*ResumeSeqACK)(nil)).Elem(),
+		ID:    33128,
+	})
+}
@@ -0,0 +1,120 @@
+package resume
+
+import (
+	"github.com/luis-quan/cellnet"
+)
+
+// 处理入站事件, 将连接上的事件转换为逻辑会话的事件, 握手及确认消息返回nil
+func ResolveInboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event, err error) {
+
+	ses := inputEvent.Session()
+
+	// 逻辑会话自己投递的事件, 例如宽限期结束的SessionClosed
+	if _, ok := ses.(*Session); ok {
+		return inputEvent, nil
+	}
+
+	switch msg := inputEvent.Message().(type) {
+	case *cellnet.SessionAccepted:
+		// 等待ResumeREQ后再通知
+		return nil, nil
+	case *cellnet.SessionConnected:
+
+		req := &ResumeREQ{}
+		if logical := sessionOf(ses); logical != nil && !logical.IsClosed() {
+			req.Token = logical.Token()
+			req.RecvSeq = logical.RecvSeq()
+		}
+
+		ses.Send(req)
+
+		// 收到ResumeACK后再通知
+		return nil, nil
+	case *ResumeREQ:
+		return ManagerOf(ses.Peer()).onRequest(ses, msg), nil
+	case *ResumeACK:
+		return onResumeACK(ses, msg), nil
+	case *ResumeSeqACK:
+
+		if logical := sessionOf(ses); logical != nil {
+			logical.onSeqAck(ses, msg)
+		}
+
+		return nil, nil
+	case *cellnet.SessionClosed:
+
+		// 握手前断开或旧连接断开时, 用户没有见过该连接
+		if logical := sessionOf(ses); logical != nil {
+			logical.onPhysicalClosed(ses, msg)
+		}
+
+		return nil, nil
+	}
+
+	logical := sessionOf(ses)
+	if logical == nil {
+		return inputEvent, nil
+	}
+
+	// 旧连接上迟到的消息, 对方会在新连接上重发
+	if !logical.onRecv(ses) {
+		return nil, nil
+	}
+
+	ev := &cellnet.RecvMsgEvent{Ses: logical, Msg: inputEvent.Message()}
+	if raw, ok := inputEvent.(*cellnet.RecvMsgEvent); ok {
+		ev.Id = raw.Id
+	}
+
+	return ev, nil
+}
+
+// 处理出站事件, 为发送的消息分配序号, 返回nil时不发送
+func ResolveOutboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event, err error) {
+
+	switch inputEvent.Message().(type) {
+	case *ResumeREQ, *ResumeACK, *ResumeSeqACK, *cellnet.SessionCloseFrame:
+		return inputEvent, nil
+	}
+
+	logical := sessionOf(inputEvent.Session())
+	if logical == nil {
+		return inputEvent, nil
+	}
+
+	return logical.onSend(inputEvent), nil
+}
+
+// 连接端收到握手结果
+func onResumeACK(phys cellnet.Session, msg *ResumeACK) cellnet.Event {
+
+	logical := sessionOf(phys)
+
+	if logical != nil && !logical.IsClosed() {
+
+		if msg.Result == ResumeResult_Resumed && msg.Token == logical.Token() {
+
+			if logical.resume(phys, msg.RecvSeq) {
+				return nil
+			}
+
+			log.Warnf("session resume failed, sesid: %d, recvseq: %d", logical.ID(), msg.RecvSeq)
+
+			// 对方缺失的消息已经被丢弃, 重新建立连接
+			logical.abandon(&cellnet.SessionClosed{Reason: cellnet.CloseReason_IO})
+			phys.Close()
+			return nil
+		}
+
+		// 对方已经结束了原来的逻辑会话
+		logical.abandon(&cellnet.SessionClosed{Reason: cellnet.CloseReason_IO})
+	}
+
+	logical = newSession(phys.Peer(), msg.SessionID, msg.Token, nil)
+
+	logical.guard.Lock()
+	logical.attach(phys, 0, nil)
+	logical.guard.Unlock()
+
+	return &cellnet.RecvMsgEvent{Ses: logical, Msg: &cellnet.SessionConnected{}}
+}
@@ -0,0 +1,515 @@
+package resume
+
+import (
+	"sync"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/peer"
+	"github.com/luis-quan/cellnet/timer"
+)
+
+// 握手结果
+const (
+	ResumeResult_New     = 0 // 新建逻辑会话
+	ResumeResult_Resumed = 1 // 恢复原有逻辑会话
+)
+
+const (
+	DefaultGracePeriod = time.Second * 30
+	DefaultMaxReplay   = 1024
+	DefaultAckInterval = 16
+)
+
+// 已发送, 等待对方确认的消息
+type replayEntry struct {
+	seq uint64
+	msg interface{}
+}
+
+// 经由逻辑会话发送的消息, 在发送钩子中分配序号
+type outMsg struct {
+	msg interface{}
+}
+
+// 恢复后重发的消息, 保持原来的序号
+type replayMsg struct {
+	msg interface{}
+}
+
+// 可恢复的逻辑会话, 底层连接断开后在宽限期内保留, 重连后绑定到新的连接并重发对方未收到的消息
+// 双方按收到的消息数量确认, 连接上的消息有序, 数量即为序号
+type Session struct {
+	peer.CoreContextSet
+
+	id    int64
+	token string
+	p     cellnet.Peer
+	opt   Option
+	mgr   *Manager // 接受端的会话管理, 连接端为nil
+
+	guard sync.Mutex
+
+	// 当前绑定的连接, 断开后为nil
+	phys cellnet.Session
+
+	sendSeq    uint64 // 最后分配的发送序号
+	recvSeq    uint64 // 已经收到的消息数量
+	evictedSeq uint64 // 超出MaxReplay被丢弃的最大序号, 对方确认到此之前无法恢复
+
+	replay   []replayEntry
+	inflight []*outMsg     // 已经交给连接, 还未到达发送钩子
+	pending  []interface{} // 断开期间发送的消息, 恢复后发送
+
+	closing      bool // 本端调用了Close
+	closeReason  cellnet.CloseReason
+	closeCode    int32
+	remoteClosed bool // 对方调用了Close
+	closed       bool
+
+	graceTimer timer.AfterStopper
+}
+
+func (self *Session) ID() int64 {
+	return self.id
+}
+
+func (self *Session) Peer() cellnet.Peer {
+	return self.p
+}
+
+// 接受端的逻辑会话由Manager管理, 身份绑定使用Manager的用户键索引, 实现peer.KeyedSessionOwner
+func (self *Session) KeyedAccessor() peer.KeyedSessionAccessor {
+
+	if self.mgr == nil {
+		return nil
+	}
+
+	return self.mgr
+}
+
+// 恢复令牌
+func (self *Session) Token() string {
+	return self.token
+}
+
+// 当前连接的原始Socket, 断开期间为nil
+func (self *Session) Raw() interface{} {
+
+	if phys := self.Physical(); phys != nil {
+		return phys.Raw()
+	}
+
+	return nil
+}
+
+// 当前绑定的连接, 断开期间为nil
+func (self *Session) Physical() cellnet.Session {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+	return self.phys
+}
+
+// 已经收到的消息数量
+func (self *Session) RecvSeq() uint64 {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+	return self.recvSeq
+}
+
+// 等待对方确认的消息数量
+func (self *Session) Unacked() int {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+	return len(self.replay) + len(self.inflight) + len(self.pending)
+}
+
+func (self *Session) IsClosed() bool {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+	return self.closed
+}
+
+// 发送消息, 断开期间消息被缓存, 恢复后发送, 缓存超过MaxReplay时放弃逻辑会话
+func (self *Session) Send(msg interface{}) {
+
+	if msg == nil {
+		return
+	}
+
+	self.guard.Lock()
+
+	if self.closed || self.closing {
+		self.guard.Unlock()
+		return
+	}
+
+	if self.phys != nil {
+		self.sendOnPhysical(msg)
+		self.guard.Unlock()
+		return
+	}
+
+	// 断开期间缓存的消息超过MaxReplay时, 恢复后也无法保证送达, 放弃逻辑会话
+	if len(self.pending) >= self.opt.MaxReplay {
+		self.guard.Unlock()
+		self.abandon(&cellnet.SessionClosed{Reason: cellnet.CloseReason_UnackedOverflow, Initiator: cellnet.CloseInitiator_Local})
+		return
+	}
+
+	self.pending = append(self.pending, msg)
+	self.guard.Unlock()
+}
+
+// 关闭逻辑会话, 通知对方不再等待恢复
+func (self *Session) Close() {
+	self.closeWith(cellnet.CloseReason_Manual, 0, false)
+}
+
+// 关闭逻辑会话, 对方的SessionClosed中包含原因及关闭码
+func (self *Session) CloseWithReason(reason cellnet.CloseReason, code int32) {
+	self.closeWith(reason, code, true)
+}
+
+func (self *Session) closeWith(reason cellnet.CloseReason, code int32, sendFrame bool) {
+
+	self.guard.Lock()
+
+	if self.closed || self.closing {
+		self.guard.Unlock()
+		return
+	}
+
+	self.closing = true
+	self.closeReason = reason
+	self.closeCode = code
+
+	phys := self.phys
+
+	if phys != nil {
+		phys.Send(&ResumeSeqACK{RecvSeq: self.recvSeq, Close: true})
+	}
+
+	self.guard.Unlock()
+
+	switch {
+	case phys == nil:
+		self.abandon(&cellnet.SessionClosed{Reason: reason, Initiator: cellnet.CloseInitiator_Local, Code: code})
+	case sendFrame:
+		cellnet.CloseSession(phys, reason, code)
+	default:
+		phys.Close()
+	}
+}
+
+func (self *Session) sendOnPhysical(msg interface{}) {
+	m := &outMsg{msg}
+	self.inflight = append(self.inflight, m)
+	self.phys.Send(m)
+}
+
+// 分配序号并保存, 等待确认
+func (self *Session) push(msg interface{}) {
+
+	self.sendSeq++
+	self.replay = append(self.replay, replayEntry{self.sendSeq, msg})
+
+	if len(self.replay) > self.opt.MaxReplay {
+		self.evictedSeq = self.replay[0].seq
+		self.replay[0] = replayEntry{}
+		self.replay = self.replay[1:]
+	}
+}
+
+// 移除对方已经收到的消息
+func (self *Session) trim(recvSeq uint64) {
+
+	var index int
+	for index < len(self.replay) && self.replay[index].seq <= recvSeq {
+		self.replay[index] = replayEntry{}
+		index++
+	}
+
+	self.replay = self.replay[index:]
+}
+
+// 对方收到recvSeq个消息时, 能否补齐后续的消息
+func (self *Session) canResume(recvSeq uint64) bool {
+	return !self.closed && recvSeq >= self.evictedSeq && recvSeq <= self.sendSeq
+}
+
+// 绑定连接, 先发送first, 再重发对方未收到的消息和断开期间缓存的消息, 调用时需要加锁
+func (self *Session) attach(phys cellnet.Session, recvSeq uint64, first interface{}) {
+
+	if self.graceTimer != nil {
+		self.graceTimer.Stop()
+		self.graceTimer = nil
+	}
+
+	self.phys = phys
+	phys.(cellnet.ContextSet).SetContext(sessionKey{}, self)
+
+	if first != nil {
+		phys.Send(first)
+	}
+
+	self.trim(recvSeq)
+
+	for _, e := range self.replay {
+		phys.Send(&replayMsg{e.msg})
+	}
+
+	pending := self.pending
+	self.pending = nil
+
+	for _, msg := range pending {
+		self.sendOnPhysical(msg)
+	}
+}
+
+// 解除连接, 还未到达发送钩子的消息放回缓存, 调用时需要加锁
+func (self *Session) detach() {
+
+	self.phys = nil
+
+	if len(self.inflight) == 0 {
+		return
+	}
+
+	pending := make([]interface{}, 0, len(self.inflight)+len(self.pending))
+	for _, m := range self.inflight {
+		pending = append(pending, m.msg)
+	}
+
+	self.pending = append(pending, self.pending...)
+	self.inflight = nil
+}
+
+// 接受端回应的握手结果, 调用时需要加锁
+func (self *Session) makeACK(result int32) *ResumeACK {
+	return &ResumeACK{
+		Token:     self.token,
+		SessionID: self.id,
+		RecvSeq:   self.recvSeq,
+		Result:    result,
+	}
+}
+
+// 用新连接恢复, 关闭仍未断开的旧连接, 接受端先回应握手结果
+func (self *Session) resume(phys cellnet.Session, recvSeq uint64) bool {
+
+	self.guard.Lock()
+
+	if !self.canResume(recvSeq) {
+		self.guard.Unlock()
+		return false
+	}
+
+	old := self.phys
+	if old != nil {
+		self.detach()
+	}
+
+	var first interface{}
+	if self.mgr != nil {
+		first = self.makeACK(ResumeResult_Resumed)
+	}
+
+	self.attach(phys, recvSeq, first)
+
+	self.guard.Unlock()
+
+	if old != nil && old != phys {
+		old.Close()
+	}
+
+	return true
+}
+
+// 发送钩子, 返回nil时不发送
+func (self *Session) onSend(ev cellnet.Event) cellnet.Event {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	current := self.phys == ev.Session()
+
+	switch m := ev.Message().(type) {
+	case *outMsg:
+
+		// 连接断开时已经放回缓存
+		if !current || len(self.inflight) == 0 || self.inflight[0] != m {
+			return nil
+		}
+
+		self.inflight[0] = nil
+		self.inflight = self.inflight[1:]
+		self.push(m.msg)
+
+		return &cellnet.SendMsgEvent{Ses: ev.Session(), Msg: m.msg}
+
+	case *replayMsg:
+
+		if !current {
+			return nil
+		}
+
+		return &cellnet.SendMsgEvent{Ses: ev.Session(), Msg: m.msg}
+	default:
+
+		// 直接通过连接发送的消息, 对方同样会计数
+		if current {
+			self.push(m)
+		}
+
+		return ev
+	}
+}
+
+// 收到消息时计数, 旧连接上迟到的消息返回false
+func (self *Session) onRecv(phys cellnet.Session) bool {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	if self.phys != phys {
+		return false
+	}
+
+	self.recvSeq++
+
+	if self.recvSeq%uint64(self.opt.AckInterval) == 0 {
+		phys.Send(&ResumeSeqACK{RecvSeq: self.recvSeq})
+	}
+
+	return true
+}
+
+func (self *Session) onSeqAck(phys cellnet.Session, msg *ResumeSeqACK) {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	if self.phys != phys {
+		return
+	}
+
+	self.trim(msg.RecvSeq)
+
+	if msg.Close {
+		self.remoteClosed = true
+	}
+}
+
+// 连接断开, 开始等待恢复
+func (self *Session) onPhysicalClosed(phys cellnet.Session, closedMsg *cellnet.SessionClosed) {
+
+	self.guard.Lock()
+
+	if self.phys != phys {
+		self.guard.Unlock()
+		return
+	}
+
+	self.detach()
+
+	if self.closing || self.remoteClosed || !waitResume(self.p) {
+
+		// 连接的关闭原因即逻辑会话的关闭原因
+		logicalClosed := *closedMsg
+		if self.closing {
+			logicalClosed = cellnet.SessionClosed{
+				Reason:    self.closeReason,
+				Initiator: cellnet.CloseInitiator_Local,
+				Code:      self.closeCode,
+			}
+		} else if isStopping(self.p) {
+			logicalClosed.Reason = cellnet.CloseReason_PeerStopping
+			logicalClosed.Initiator = cellnet.CloseInitiator_Local
+		}
+
+		self.guard.Unlock()
+		self.abandon(&logicalClosed)
+		return
+	}
+
+	self.graceTimer = timer.CurrentClock().AfterFunc(self.opt.GracePeriod, func() {
+
+		self.guard.Lock()
+		expired := self.phys == nil
+		self.guard.Unlock()
+
+		// 使用最后一次断开的原因
+		if expired {
+			self.abandon(closedMsg)
+		}
+	})
+
+	self.guard.Unlock()
+}
+
+// 结束逻辑会话, 通知SessionClosed
+func (self *Session) abandon(closedMsg *cellnet.SessionClosed) {
+
+	self.guard.Lock()
+
+	if self.closed {
+		self.guard.Unlock()
+		return
+	}
+
+	self.closed = true
+
+	if self.graceTimer != nil {
+		self.graceTimer.Stop()
+		self.graceTimer = nil
+	}
+
+	self.replay = nil
+	self.inflight = nil
+	self.pending = nil
+
+	self.guard.Unlock()
+
+	if self.mgr != nil {
+		self.mgr.remove(self)
+	}
+
+	self.p.(peer.MessagePoster).ProcEvent(&cellnet.RecvMsgEvent{Ses: self, Msg: closedMsg})
+}
+
+func isStopping(p cellnet.Peer) bool {
+
+	stopper, ok := p.(interface {
+		IsStopping() bool
+	})
+
+	return ok && stopper.IsStopping()
+}
+
+// Peer停止或连接器不重连时, 不等待恢复
+func waitResume(p cellnet.Peer) bool {
+
+	if isStopping(p) {
+		return false
+	}
+
+	if reconn, ok := p.(interface {
+		ReconnectDuration() time.Duration
+	}); ok && reconn.ReconnectDuration() == 0 {
+		return false
+	}
+
+	return true
+}
+
+func newSession(p cellnet.Peer, id int64, token string, mgr *Manager) *Session {
+
+	return &Session{
+		id:    id,
+		token: token,
+		p:     p,
+		opt:   optionOf(p),
+		mgr:   mgr,
+	}
+}
@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+)
+
+// 会话上未完成的请求及流, CallID及StreamID在会话内分配, 不同会话(不同Peer)间互不影响
+type sessionCalls struct {
+	guard   sync.Mutex
+	idSeq   int64
+	reqByID map[int64]*request
+
+	streamSeq   int64
+	streamByKey map[streamKey]*Stream
+}
+
+func (self *sessionCalls) add(req *request) {
+	self.guard.Lock()
+	self.idSeq++
+	req.id = self.idSeq
+	self.reqByID[req.id] = req
+	self.guard.Unlock()
+}
+
+func (self *sessionCalls) remove(callid int64) *request {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	req, ok := self.reqByID[callid]
+	if !ok {
+		return nil
+	}
+
+	delete(self.reqByID, callid)
+	return req
+}
+
+func (self *sessionCalls) has(callid int64) bool {
+	self.guard.Lock()
+	_, ok := self.reqByID[callid]
+	self.guard.Unlock()
+	return ok
+}
+
+// 回应类型为ackType的最早的请求, 没有时返回0
+func (self *sessionCalls) oldestByType(ackType reflect.Type) (ret int64) {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	for id, req := range self.reqByID {
+		if req.ackType == ackType && (ret == 0 || id < ret) {
+			ret = id
+		}
+	}
+
+	return
+}
+
+func (self *sessionCalls) ids() (ret []int64) {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	for id := range self.reqByID {
+		ret = append(ret, id)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+
+	return
+}
+
+type sessionCallsKey struct{}
+
+var (
+	sessionCallsGuard sync.Mutex
+
+	// 不支持ContextSet的会话, 按会话保存
+	callsBySession = map[cellnet.Session]*sessionCalls{}
+)
+
+func getSessionCalls(ses cellnet.Session, create bool) *sessionCalls {
+
+	sessionCallsGuard.Lock()
+	defer sessionCallsGuard.Unlock()
+
+	ctxSet, isCtxSet := ses.(cellnet.ContextSet)
+
+	if isCtxSet {
+		if raw, ok := ctxSet.GetContext(sessionCallsKey{}); ok {
+			return raw.(*sessionCalls)
+		}
+	} else if calls, ok := callsBySession[ses]; ok {
+		return calls
+	}
+
+	if !create {
+		return nil
+	}
+
+	calls := &sessionCalls{
+		reqByID:     make(map[int64]*request),
+		streamByKey: make(map[streamKey]*Stream),
+	}
+
+	if isCtxSet {
+		ctxSet.SetContext(sessionCallsKey{}, calls)
+	} else {
+		callsBySession[ses] = calls
+	}
+
+	return calls
+}
+
+// 会话关闭后, 释放不支持ContextSet的会话的请求表
+func releaseSessionCalls(ses cellnet.Session) {
+
+	sessionCallsGuard.Lock()
+	delete(callsBySession, ses)
+	sessionCallsGuard.Unlock()
+}
+
+// 未完成的请求信息
+type PendingCall struct {
+	CallID   int64
+	Request  interface{} // 请求消息
+	Start    time.Time   // 发起请求的时间
+	Deadline time.Time   // 超时时间, 零值表示不超时
+}
+
+// 获取会话上所有未完成的请求, 按CallID排序
+func PendingCalls(ses cellnet.Session) (ret []PendingCall) {
+
+	calls := getSessionCalls(ses, false)
+	if calls == nil {
+		return
+	}
+
+	calls.guard.Lock()
+	for _, req := range calls.reqByID {
+		ret = append(ret, PendingCall{
+			CallID:   req.id,
+			Request:  req.msg,
+			Start:    req.start,
+			Deadline: req.deadline,
+		})
+	}
+	calls.guard.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CallID < ret[j].CallID
+	})
+
+	return
+}
+
+// 取消会话上的请求, 请求的回调收到ErrCanceled, 请求不存在时返回false
+func CancelCall(ses cellnet.Session, callID int64) bool {
+
+	req := getRequest(ses, callID)
+	if req == nil {
+		return false
+	}
+
+	req.RecvFeedback(ErrCanceled)
+
+	return true
+}
+
+// 取消会话上的所有请求, 返回取消的数量
+func CancelAllCalls(ses cellnet.Session) int {
+
+	reqList := takeSessionRequests(ses)
+
+	for _, req := range reqList {
+		req.RecvFeedback(ErrCanceled)
+	}
+
+	return len(reqList)
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import "fmt"
+
+// RPC回应的状态码, 随RemoteCallACK传回调用方
+type StatusCode int32
+
+const (
+	StatusCode_OK                StatusCode = iota // 成功
+	StatusCode_Unknown                             // 未知错误
+	StatusCode_InvalidArgument                     // 请求参数错误
+	StatusCode_NotFound                            // 请求的对象不存在
+	StatusCode_PermissionDenied                    // 没有权限
+	StatusCode_Unavailable                         // 服务暂时不可用
+	StatusCode_Internal                            // 服务器内部错误
+	StatusCode_Unimplemented                       // 服务器不支持该请求
+	StatusCode_DeadlineExceeded                    // 处理前已经超过调用方的期限
+	StatusCode_ResourceExhausted                   // 超过流的接收窗口或数量限制
+)
+
+func (self StatusCode) String() string {
+	switch self {
+	case StatusCode_OK:
+		return "OK"
+	case StatusCode_Unknown:
+		return "Unknown"
+	case StatusCode_InvalidArgument:
+		return "InvalidArgument"
+	case StatusCode_NotFound:
+		return "NotFound"
+	case StatusCode_PermissionDenied:
+		return "PermissionDenied"
+	case StatusCode_Unavailable:
+		return "Unavailable"
+	case StatusCode_Internal:
+		return "Internal"
+	case StatusCode_Unimplemented:
+		return "Unimplemented"
+	case StatusCode_DeadlineExceeded:
+		return "DeadlineExceeded"
+	case StatusCode_ResourceExhausted:
+		return "ResourceExhausted"
+	}
+
+	return fmt.Sprintf("StatusCode(%d)", int32(self))
+}
+
+// 服务器通过ReplyError回应的错误, Call回调及CallSync返回的error可断言为*CallError
+type CallError struct {
+	Code StatusCode
+	Msg  string
+}
+
+func (self *CallError) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", self.Code, self.Msg)
+}
+
+// 获取error中的状态码, nil返回StatusCode_OK, 非CallError返回StatusCode_Unknown
+func CodeOf(err error) StatusCode {
+
+	if err == nil {
+		return StatusCode_OK
+	}
+
+	if callErr, ok := err.(*CallError); ok {
+		return callErr.Code
+	}
+
+	return StatusCode_Unknown
+}
@@ -1,14 +1,33 @@
 package rpc
 
 import (
+	"time"
+
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/codec"
+	"github.com/luis-quan/cellnet/msglog"
 )
 
 type RecvMsgEvent struct {
 	ses    cellnet.Session
 	Msg    interface{}
 	callid int64
+
+	// 请求元数据
+	Meta Metadata
+
+	// 回应时附带的元数据, 在Reply前设置
+	ReplyMeta Metadata
+
+	deadline time.Time
+
+	// 由LogServerInterceptor设置, 回应时记录日志
+	logReply bool
+}
+
+// 调用方传递的期限, 没有期限时为零值
+func (self *RecvMsgEvent) Deadline() time.Time {
+	return self.deadline
 }
 
 func (self *RecvMsgEvent) Session() cellnet.Session {
@@ -19,6 +38,11 @@ func (self *RecvMsgEvent) Message() interface{} {
 	return self.Msg
 }
 
+// 请求的CallID, 在发起请求的会话内唯一
+func (self *RecvMsgEvent) CallID() int64 {
+	return self.callid
+}
+
 func (self *RecvMsgEvent) Queue() cellnet.EventQueue {
 	return self.ses.Peer().(interface {
 		Queue() cellnet.EventQueue
@@ -34,9 +58,49 @@ func (self *RecvMsgEvent) Reply(msg interface{}) {
 		return
 	}
 
+	if self.logReply {
+		msglog.WriteSendLogger(log, "rpc", self.ses, msg)
+	}
+
 	self.ses.Send(&RemoteCallACK{
 		MsgID:  uint32(meta.ID),
 		Data:   data,
 		CallID: self.callid,
+		Meta:   self.ReplyMeta.pairs(),
+	})
+}
+
+// 回应错误, 调用方收到*CallError
+func (self *RecvMsgEvent) ReplyError(code StatusCode, msg string) {
+
+	// OK不是错误, 避免调用方误认为成功却收不到消息
+	if code == StatusCode_OK {
+		code = StatusCode_Unknown
+	}
+
+	if self.logReply {
+		writeErrorLog("send", self.ses, &CallError{Code: code, Msg: msg})
+	}
+
+	self.ses.Send(&RemoteCallACK{
+		CallID: self.callid,
+		Code:   int32(code),
+		Error:  msg,
+		Meta:   self.ReplyMeta.pairs(),
 	})
 }
+
+// 按处理结果回应, err为*CallError时保留状态码, 其他error以StatusCode_Internal回应
+func (self *RecvMsgEvent) ReplyResult(msg interface{}, err error) {
+
+	if err == nil {
+		self.Reply(msg)
+		return
+	}
+
+	if callErr, ok := err.(*CallError); ok {
+		self.ReplyError(callErr.Code, callErr.Msg)
+	} else {
+		self.ReplyError(StatusCode_Internal, err.Error())
+	}
+}
@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/timer"
+)
+
+// 请求信息, 客户端拦截器可以读取及修改
+type CallInfo struct {
+	Session cellnet.Session
+	Timeout time.Duration
+	Sync    bool     // 是否为同步请求(CallSync)
+	Meta    Metadata // 请求元数据
+
+	replyMeta *Metadata
+	deadline  time.Time
+	ackType   reflect.Type // 类型RPC的回应类型
+}
+
+// 发送请求, onReply收到回应消息或error
+type ClientInvoker func(info *CallInfo, reqMsg interface{}, onReply func(feedback interface{}))
+
+// 客户端拦截器, 可以包装onReply观察回应, 或者不调用invoker直接以onReply(error)结束请求
+// invoker需要在拦截器返回前调用
+type ClientInterceptor func(info *CallInfo, reqMsg interface{}, onReply func(feedback interface{}), invoker ClientInvoker)
+
+// 执行服务器处理回调
+type ServerInvoker func(ev *RecvMsgEvent)
+
+// 服务器拦截器, 在处理回调前执行, 可以通过ev.ReplyError回应错误并且不调用invoker
+// 请求已经超过调用方传递的期限时, 直接回应StatusCode_DeadlineExceeded, 不执行拦截器
+type ServerInterceptor func(ev *RecvMsgEvent, invoker ServerInvoker)
+
+var (
+	interceptorGuard   sync.RWMutex
+	clientInterceptors []ClientInterceptor
+	serverInterceptors []ServerInterceptor
+)
+
+// 添加客户端拦截器, 按添加顺序执行, 先添加的在外层
+func AddClientInterceptor(interceptor ClientInterceptor) {
+
+	interceptorGuard.Lock()
+	clientInterceptors = append(clientInterceptors[:len(clientInterceptors):len(clientInterceptors)], interceptor)
+	interceptorGuard.Unlock()
+}
+
+// 添加服务器拦截器, 按添加顺序执行, 先添加的在外层
+func AddServerInterceptor(interceptor ServerInterceptor) {
+
+	interceptorGuard.Lock()
+	serverInterceptors = append(serverInterceptors[:len(serverInterceptors):len(serverInterceptors)], interceptor)
+	interceptorGuard.Unlock()
+}
+
+// 清除所有拦截器
+func ClearInterceptors() {
+
+	interceptorGuard.Lock()
+	clientInterceptors = nil
+	serverInterceptors = nil
+	interceptorGuard.Unlock()
+}
+
+// 经过客户端拦截器发送请求, 请求被拦截时返回nil
+func invokeClient(info *CallInfo, reqMsg interface{}, onReply func(feedback interface{})) (req *request) {
+
+	interceptorGuard.RLock()
+	list := clientInterceptors
+	interceptorGuard.RUnlock()
+
+	var invoker ClientInvoker = func(info *CallInfo, reqMsg interface{}, onReply func(feedback interface{})) {
+		req = createRequest(info.Session, reqMsg, info.Timeout, onReply)
+		req.meta = info.sendPairs()
+		req.replyMeta = info.replyMeta
+		req.ackType = info.ackType
+		req.Send()
+	}
+
+	for i := len(list) - 1; i >= 0; i-- {
+		interceptor, next := list[i], invoker
+		invoker = func(info *CallInfo, reqMsg interface{}, onReply func(feedback interface{})) {
+			interceptor(info, reqMsg, onReply, next)
+		}
+	}
+
+	invoker(info, reqMsg, onReply)
+
+	return
+}
+
+// 经过服务器拦截器执行处理回调
+func invokeServer(ev *RecvMsgEvent, callback cellnet.EventCallback) {
+
+	interceptorGuard.RLock()
+	list := serverInterceptors
+	interceptorGuard.RUnlock()
+
+	// 调用方已经超时, 不再处理
+	if !ev.deadline.IsZero() && !timer.Now().Before(ev.deadline) {
+		ev.ReplyError(StatusCode_DeadlineExceeded, "deadline exceeded before handling")
+		return
+	}
+
+	var invoker ServerInvoker = func(ev *RecvMsgEvent) {
+		callback(ev)
+	}
+
+	for i := len(list) - 1; i >= 0; i-- {
+		interceptor, next := list[i], invoker
+		invoker = func(ev *RecvMsgEvent) {
+			interceptor(ev, next)
+		}
+	}
+
+	invoker(ev)
+}
+
+// 让收到的RPC请求经过服务器拦截器后再交给userCallback, 其他事件直接交给userCallback
+// 只有处理RPC消息的处理器(tcp.ltv, tcp.ltv.resume)需要使用, ws及udp处理器的Hooker不处理RPC, 不会产生*RecvMsgEvent
+func NewInterceptedEventCallback(userCallback cellnet.EventCallback) cellnet.EventCallback {
+
+	if userCallback == nil {
+		return nil
+	}
+
+	return func(ev cellnet.Event) {
+
+		if rpcEv, ok := ev.(*RecvMsgEvent); ok {
+			invokeServer(rpcEv, userCallback)
+		} else {
+			userCallback(ev)
+		}
+	}
+}
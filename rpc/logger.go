@@ -0,0 +1,44 @@
+package rpc
+
+import (
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/msglog"
+)
+
+// 记录请求及回应日志的客户端拦截器, 使用AddClientInterceptor添加
+func LogClientInterceptor(info *CallInfo, reqMsg interface{}, onReply func(feedback interface{}), invoker ClientInvoker) {
+
+	msglog.WriteSendLogger(log, "rpc", info.Session, reqMsg)
+
+	invoker(info, reqMsg, func(feedback interface{}) {
+
+		if err, ok := feedback.(error); ok {
+			writeErrorLog("recv", info.Session, err)
+		} else {
+			msglog.WriteRecvLogger(log, "rpc", info.Session, feedback)
+		}
+
+		onReply(feedback)
+	})
+}
+
+// 记录请求及回应日志的服务器拦截器, 使用AddServerInterceptor添加
+func LogServerInterceptor(ev *RecvMsgEvent, invoker ServerInvoker) {
+
+	msglog.WriteRecvLogger(log, "rpc", ev.Session(), ev.Msg)
+
+	ev.logReply = true
+
+	invoker(ev)
+}
+
+func writeErrorLog(dir string, ses cellnet.Session, err error) {
+
+	if log.IsDebugEnabled() {
+		log.Debugf("#rpc.%s(%s)@%d error: %v",
+			dir,
+			ses.Peer().(cellnet.PeerProperty).Name(),
+			ses.ID(),
+			err)
+	}
+}
@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/timer"
+)
+
+// 请求及回应附带的元数据, 例如追踪ID, 鉴权令牌
+type Metadata map[string]string
+
+// 请求剩余的超时时间(毫秒), 发送请求时自动填写, 服务器据此计算期限
+const MetaKey_Timeout = "rpc-timeout"
+
+func (self Metadata) Get(key string) string {
+	return self[key]
+}
+
+func (self Metadata) Clone() Metadata {
+
+	if self == nil {
+		return nil
+	}
+
+	ret := make(Metadata, len(self))
+	for k, v := range self {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+// 按键排序展开为键值对, 用于传输
+func (self Metadata) pairs() (ret []string) {
+
+	if len(self) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(self))
+	for k := range self {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ret = append(ret, k, self[k])
+	}
+
+	return
+}
+
+func metadataFromPairs(pairs []string) Metadata {
+
+	if len(pairs) < 2 {
+		return nil
+	}
+
+	ret := make(Metadata, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		ret[pairs[i]] = pairs[i+1]
+	}
+
+	return ret
+}
+
+// 请求选项, 用于Call/CallSync等
+type CallOption func(info *CallInfo)
+
+// 添加请求元数据
+func WithMeta(key, value string) CallOption {
+	return func(info *CallInfo) {
+		if info.Meta == nil {
+			info.Meta = make(Metadata)
+		}
+
+		info.Meta[key] = value
+	}
+}
+
+// 添加多个请求元数据
+func WithMetadata(md Metadata) CallOption {
+	return func(info *CallInfo) {
+		for k, v := range md {
+			WithMeta(k, v)(info)
+		}
+	}
+}
+
+// 请求期限, 比timeout更早时生效, 常用于服务器处理中转发请求时传递RecvMsgEvent.Deadline()
+func WithDeadline(deadline time.Time) CallOption {
+	return func(info *CallInfo) {
+
+		if deadline.IsZero() {
+			return
+		}
+
+		timeout := timer.Until(deadline)
+
+		// 已经过期, 立即超时
+		if timeout <= 0 {
+			timeout = time.Nanosecond
+		}
+
+		if info.Timeout <= 0 || timeout < info.Timeout {
+			info.Timeout = timeout
+		}
+	}
+}
+
+// 收到回应时, 将回应的元数据写入dst
+func WithReplyMeta(dst *Metadata) CallOption {
+	return func(info *CallInfo) {
+		info.replyMeta = dst
+	}
+}
+
+func newCallInfo(ctx context.Context, ses cellnet.Session, timeout time.Duration, sync bool, opts []CallOption) *CallInfo {
+
+	info := &CallInfo{
+		Session: ses,
+		Timeout: timeout,
+		Sync:    sync,
+	}
+
+	// ctx的期限只传递给服务器, 本地由ctx负责超时
+	info.deadline, _ = ctx.Deadline()
+
+	for _, opt := range opts {
+		opt(info)
+	}
+
+	return info
+}
+
+// 发送时附带的元数据, 包含剩余超时时间
+func (self *CallInfo) sendPairs() []string {
+
+	timeout := self.Timeout
+
+	if !self.deadline.IsZero() {
+		if remain := timer.Until(self.deadline); timeout <= 0 || remain < timeout {
+			timeout = remain
+		}
+	}
+
+	if timeout <= 0 {
+		return self.Meta.pairs()
+	}
+
+	md := self.Meta.Clone()
+	if md == nil {
+		md = make(Metadata)
+	}
+
+	// 不足1毫秒按1毫秒计算, 避免被当作没有期限
+	ms := int64((timeout + time.Millisecond - 1) / time.Millisecond)
+	md[MetaKey_Timeout] = strconv.FormatInt(ms, 10)
+
+	return md.pairs()
+}
+
+// 从请求元数据计算服务器端的期限
+func deadlineFromMeta(md Metadata) time.Time {
+
+	ms, err := strconv.ParseInt(md.Get(MetaKey_Timeout), 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+
+	return timer.Now().Add(time.Duration(ms) * time.Millisecond)
+}
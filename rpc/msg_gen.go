@@ -23,6 +23,7 @@ type RemoteCallREQ struct {
 	MsgID  uint32
 	Data   []byte
 	CallID int64
+	Meta   []string // 元数据, 按键值对展开
 }
 
 func (self *RemoteCallREQ) String() string { return proto.CompactTextString(self) }
@@ -35,6 +36,8 @@ func (self *RemoteCallREQ) Size() (ret int) {
 
 	ret += proto.SizeInt64(2, self.CallID)
 
+	ret += proto.SizeStringSlice(3, self.Meta)
+
 	return
 }
 
@@ -46,6 +49,8 @@ func (self *RemoteCallREQ) Marshal(buffer *proto.Buffer) error {
 
 	proto.MarshalInt64(buffer, 2, self.CallID)
 
+	proto.MarshalStringSlice(buffer, 3, self.Meta)
+
 	return nil
 }
 
@@ -57,6 +62,8 @@ func (self *RemoteCallREQ) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt
 		return proto.UnmarshalBytes(buffer, wt, &self.Data)
 	case 2:
 		return proto.UnmarshalInt64(buffer, wt, &self.CallID)
+	case 3:
+		return proto.UnmarshalStringSlice(buffer, wt, &self.Meta)
 
 	}
 
@@ -67,6 +74,9 @@ type RemoteCallACK struct {
 	MsgID  uint32
 	Data   []byte
 	CallID int64
+	Code   int32
+	Error  string
+	Meta   []string // 元数据, 按键值对展开
 }
 
 func (self *RemoteCallACK) String() string { return proto.CompactTextString(self) }
@@ -79,6 +89,12 @@ func (self *RemoteCallACK) Size() (ret int) {
 
 	ret += proto.SizeInt64(2, self.CallID)
 
+	ret += proto.SizeInt32(3, self.Code)
+
+	ret += proto.SizeString(4, self.Error)
+
+	ret += proto.SizeStringSlice(5, self.Meta)
+
 	return
 }
 
@@ -90,6 +106,12 @@ func (self *RemoteCallACK) Marshal(buffer *proto.Buffer) error {
 
 	proto.MarshalInt64(buffer, 2, self.CallID)
 
+	proto.MarshalInt32(buffer, 3, self.Code)
+
+	proto.MarshalString(buffer, 4, self.Error)
+
+	proto.MarshalStringSlice(buffer, 5, self.Meta)
+
 	return nil
 }
 
@@ -101,6 +123,91 @@ func (self *RemoteCallACK) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt
 		return proto.UnmarshalBytes(buffer, wt, &self.Data)
 	case 2:
 		return proto.UnmarshalInt64(buffer, wt, &self.CallID)
+	case 3:
+		return proto.UnmarshalInt32(buffer, wt, &self.Code)
+	case 4:
+		return proto.UnmarshalString(buffer, wt, &self.Error)
+	case 5:
+		return proto.UnmarshalStringSlice(buffer, wt, &self.Meta)
+
+	}
+
+	return proto.ErrUnknownField
+}
+
+type RemoteStreamFrame struct {
+	StreamID  int64
+	Kind      int32
+	Initiator bool
+	MsgID     uint32
+	Data      []byte
+	Credit    int32
+	Code      int32
+	Error     string
+}
+
+func (self *RemoteStreamFrame) String() string { return proto.CompactTextString(self) }
+
+func (self *RemoteStreamFrame) Size() (ret int) {
+
+	ret += proto.SizeInt64(0, self.StreamID)
+
+	ret += proto.SizeInt32(1, self.Kind)
+
+	ret += proto.SizeBool(2, self.Initiator)
+
+	ret += proto.SizeUInt32(3, self.MsgID)
+
+	ret += proto.SizeBytes(4, self.Data)
+
+	ret += proto.SizeInt32(5, self.Credit)
+
+	ret += proto.SizeInt32(6, self.Code)
+
+	ret += proto.SizeString(7, self.Error)
+
+	return
+}
+
+func (self *RemoteStreamFrame) Marshal(buffer *proto.Buffer) error {
+
+	proto.MarshalInt64(buffer, 0, self.StreamID)
+
+	proto.MarshalInt32(buffer, 1, self.Kind)
+
+	proto.MarshalBool(buffer, 2, self.Initiator)
+
+	proto.MarshalUInt32(buffer, 3, self.MsgID)
+
+	proto.MarshalBytes(buffer, 4, self.Data)
+
+	proto.MarshalInt32(buffer, 5, self.Credit)
+
+	proto.MarshalInt32(buffer, 6, self.Code)
+
+	proto.MarshalString(buffer, 7, self.Error)
+
+	return nil
+}
+
+func (self *RemoteStreamFrame) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt proto.WireType) error {
+	switch fieldIndex {
+	case 0:
+		return proto.UnmarshalInt64(buffer, wt, &self.StreamID)
+	case 1:
+		return proto.UnmarshalInt32(buffer, wt, &self.Kind)
+	case 2:
+		return proto.UnmarshalBool(buffer, wt, &self.Initiator)
+	case 3:
+		return proto.UnmarshalUInt32(buffer, wt, &self.MsgID)
+	case 4:
+		return proto.UnmarshalBytes(buffer, wt, &self.Data)
+	case 5:
+		return proto.UnmarshalInt32(buffer, wt, &self.Credit)
+	case 6:
+		return proto.UnmarshalInt32(buffer, wt, &self.Code)
+	case 7:
+		return proto.UnmarshalString(buffer, wt, &self.Error)
 
 	}
 
@@ -119,4 +226,9 @@ func init() {
 		Type:  reflect.TypeOf((*RemoteCallACK)(nil)).Elem(),
 		ID:    20476,
 	})
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("protoplus"),
+		Type:  reflect.TypeOf((*RemoteStreamFrame)(nil)).Elem(),
+		ID:    56630,
+	})
 }
@@ -3,7 +3,6 @@ package rpc
 import (
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/codec"
-	"github.com/luis-quan/cellnet/msglog"
 )
 
 type RemoteCallMsg interface {
@@ -14,44 +13,65 @@ type RemoteCallMsg interface {
 
 func ResolveInboundEvent(inputEvent cellnet.Event) (ouputEvent cellnet.Event, handled bool, err error) {
 
-	if _, ok := inputEvent.(*RecvMsgEvent); ok {
+	switch inputEvent.(type) {
+	case *RecvMsgEvent, *RecvStreamEvent:
 		return inputEvent, false, nil
 	}
 
+	// 会话断开时, 未回应的请求不再等待超时
+	if _, ok := inputEvent.Message().(*cellnet.SessionClosed); ok {
+		failSessionRequests(inputEvent.Session())
+		return inputEvent, false, nil
+	}
+
+	// 流帧
+	if frame, ok := inputEvent.Message().(*RemoteStreamFrame); ok {
+		outputEvent, err := resolveStreamFrame(inputEvent, frame)
+		return outputEvent, true, err
+	}
+
 	rpcMsg, ok := inputEvent.Message().(RemoteCallMsg)
 	if !ok {
 		return inputEvent, false, nil
 	}
 
-	userMsg, _, err := codec.DecodeMessage(int(rpcMsg.GetMsgID()), rpcMsg.GetMsgData())
+	// 服务器回应错误, 没有消息体
+	if ack, ok := rpcMsg.(*RemoteCallACK); ok && ack.Code != int32(StatusCode_OK) {
+		request := getRequest(inputEvent.Session(), ack.CallID)
+		if request != nil {
+			request.recvMeta(ack.Meta)
+			request.RecvFeedback(&CallError{
+				Code: StatusCode(ack.Code),
+				Msg:  ack.Error,
+			})
+		}
 
-	if err != nil {
-		return inputEvent, false, err
+		return inputEvent, true, nil
 	}
 
-	if msglog.IsMsgLogValid(int(rpcMsg.GetMsgID())) {
-		peerInfo := inputEvent.Session().Peer().(cellnet.PeerProperty)
+	userMsg, _, err := codec.DecodeMessage(int(rpcMsg.GetMsgID()), rpcMsg.GetMsgData())
 
-		log.Debugf("#rpc.recv(%s)@%d len: %d %s | %s",
-			peerInfo.Name(),
-			inputEvent.Session().ID(),
-			cellnet.MessageSize(userMsg),
-			cellnet.MessageToName(userMsg),
-			cellnet.MessageToString(userMsg))
+	if err != nil {
+		return inputEvent, false, err
 	}
 
 	switch inputEvent.Message().(type) {
 	case *RemoteCallREQ: // 服务端收到客户端的请求
 
+		md := metadataFromPairs(rpcMsg.(*RemoteCallREQ).Meta)
+
 		return &RecvMsgEvent{
-			inputEvent.Session(),
-			userMsg,
-			rpcMsg.GetCallID(),
+			ses:      inputEvent.Session(),
+			Msg:      userMsg,
+			callid:   rpcMsg.GetCallID(),
+			Meta:     md,
+			deadline: deadlineFromMeta(md),
 		}, true, nil
 
-	case *RemoteCallACK: // 客户端收到服务器的回应
-		request := getRequest(rpcMsg.GetCallID())
+	case *RemoteCallACK: // 客户端收到服务器的回应, CallID只在发出请求的会话内有效
+		request := getRequest(inputEvent.Session(), rpcMsg.GetCallID())
 		if request != nil {
+			request.recvMeta(rpcMsg.(*RemoteCallACK).Meta)
 			request.RecvFeedback(userMsg)
 		}
 
@@ -61,30 +81,13 @@ func ResolveInboundEvent(inputEvent cellnet.Event) (ouputEvent cellnet.Event, ha
 	return inputEvent, false, nil
 }
 
+// 请求及回应的日志由LogClientInterceptor及LogServerInterceptor记录
 func ResolveOutboundEvent(inputEvent cellnet.Event) (handled bool, err error) {
-	rpcMsg, ok := inputEvent.Message().(RemoteCallMsg)
-	if !ok {
-		return false, nil
-	}
-
-	userMsg, _, err := codec.DecodeMessage(int(rpcMsg.GetMsgID()), rpcMsg.GetMsgData())
-
-	if err != nil {
-		return false, err
-	}
-
-	if msglog.IsMsgLogValid(int(rpcMsg.GetMsgID())) {
-		peerInfo := inputEvent.Session().Peer().(cellnet.PeerProperty)
-
-		log.Debugf("#rpc.send(%s)@%d len: %d %s | %s",
-			peerInfo.Name(),
-			inputEvent.Session().ID(),
-			cellnet.MessageSize(userMsg),
-			cellnet.MessageToName(userMsg),
-			cellnet.MessageToString(userMsg))
-	}
 
 	// 避免后续环节处理
+	if _, ok := inputEvent.Message().(RemoteCallMsg); ok {
+		return true, nil
+	}
 
-	return true, nil
+	return false, nil
 }
@@ -2,71 +2,153 @@ package rpc
 
 import (
 	"errors"
+	"reflect"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/codec"
-)
-
-var (
-	rpcIDSeq        int64
-	requestByCallID sync.Map
+	"github.com/luis-quan/cellnet/timer"
 )
 
 type request struct {
 	id     int64
+	ses    cellnet.Session
+	msg    interface{}
 	onRecv func(interface{})
+
+	meta      []string  // 发送的元数据
+	replyMeta *Metadata // 收到回应时写入回应的元数据
+
+	ackType reflect.Type // 类型RPC的回应类型, 服务器直接发送回应消息时按类型对应
+
+	start    time.Time
+	deadline time.Time // 零值表示不超时
+
+	// 在超时调度器中的位置, -1表示不在调度器中
+	timeoutIndex int
+
+	// 请求结束(收到回应, 超时, 取消)时关闭
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
-var ErrTimeout = errors.New("RPC time out")
+var (
+	ErrTimeout       = errors.New("RPC time out")
+	ErrSessionClosed = errors.New("rpc: session closed before reply")
+	ErrCanceled      = errors.New("rpc: call canceled")
+)
 
+// 回应消息, 或者error(超时, 会话关闭等)
 func (self *request) RecvFeedback(msg interface{}) {
 
 	// 异步和同步执行复杂，队列处理在具体的逻辑中手动处理
 	self.onRecv(msg)
 }
 
-func (self *request) Send(ses cellnet.Session, msg interface{}) {
+func (self *request) recvMeta(pairs []string) {
+	if self.replyMeta != nil {
+		*self.replyMeta = metadataFromPairs(pairs)
+	}
+}
+
+func (self *request) finish() {
+	self.doneOnce.Do(func() {
+		close(self.done)
+	})
+}
+
+func (self *request) Send() {
 
 	//ctx, _ := ses.(cellnet.ContextSet)
 
-	data, meta, err := codec.EncodeMessage(msg, nil)
+	data, meta, err := codec.EncodeMessage(self.msg, nil)
 
 	if err != nil {
 		log.Errorf("rpc request message encode error: %s", err)
 		return
 	}
 
-	ses.Send(&RemoteCallREQ{
+	self.ses.Send(&RemoteCallREQ{
 		MsgID:  uint32(meta.ID),
 		Data:   data,
 		CallID: self.id,
+		Meta:   self.meta,
 	})
 
 	//codec.FreeCodecResource(meta.Codec, data, ctx)
 }
 
-func createRequest(onRecv func(interface{})) *request {
+// 创建请求并登记到会话, timeout大于0时由超时调度器负责超时
+func createRequest(ses cellnet.Session, msg interface{}, timeout time.Duration, onRecv func(interface{})) *request {
 
 	self := &request{
-		onRecv: onRecv,
+		ses:          ses,
+		msg:          msg,
+		onRecv:       onRecv,
+		start:        timer.Now(),
+		timeoutIndex: -1,
+		done:         make(chan struct{}),
 	}
 
-	self.id = atomic.AddInt64(&rpcIDSeq, 1)
+	if timeout > 0 {
+		self.deadline = self.start.Add(timeout)
+	}
 
-	requestByCallID.Store(self.id, self)
+	getSessionCalls(ses, true).add(self)
+
+	if timeout > 0 {
+		timeoutSched.add(self)
+	}
 
 	return self
 }
 
-func getRequest(callid int64) *request {
+// 取出会话上的请求, 请求不存在(已经回应, 超时或取消)时返回nil
+func getRequest(ses cellnet.Session, callid int64) *request {
+
+	calls := getSessionCalls(ses, false)
+	if calls == nil {
+		return nil
+	}
+
+	req := calls.remove(callid)
+	if req == nil {
+		return nil
+	}
 
-	if v, ok := requestByCallID.Load(callid); ok {
+	timeoutSched.remove(req)
+	req.finish()
+	return req
+}
+
+// 会话断开时, 未回应的请求及未结束的流立即以ErrSessionClosed失败
+func failSessionRequests(ses cellnet.Session) {
+
+	for _, req := range takeSessionRequests(ses) {
+		req.RecvFeedback(ErrSessionClosed)
+	}
+
+	for _, stream := range takeSessionStreams(ses) {
+		stream.fail(ErrSessionClosed)
+	}
+
+	releaseSessionCalls(ses)
+}
+
+// 取出会话上的所有请求
+func takeSessionRequests(ses cellnet.Session) (ret []*request) {
+
+	calls := getSessionCalls(ses, false)
+	if calls == nil {
+		return
+	}
 
-		requestByCallID.Delete(callid)
-		return v.(*request)
+	for _, id := range calls.ids() {
+		if req := getRequest(ses, id); req != nil {
+			ret = append(ret, req)
+		}
 	}
 
-	return nil
+	return
 }
@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"time"
 
 	"github.com/luis-quan/cellnet"
@@ -8,7 +9,35 @@ import (
 
 // 异步RPC请求
 // ud: peer/session,   reqMsg:请求用的消息, userCallback: 返回消息类型回调 func( ackMsg *ackMsgType)
-func Call(sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, userCallback func(raw interface{})) {
+// timeout不大于0时立即回调ErrTimeout, 不限时的请求使用CallContext
+// opts: 请求选项, 例如WithMeta
+func Call(sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, userCallback func(raw interface{}), opts ...CallOption) {
+
+	if timeout <= 0 {
+		ses, err := getPeerSession(sesOrPeer)
+
+		if err == nil {
+			err = ErrTimeout
+		}
+
+		cellnet.SessionQueuedCall(ses, func() {
+			userCallback(err)
+		})
+
+		return
+	}
+
+	call(context.Background(), sesOrPeer, reqMsg, timeout, userCallback, opts)
+}
+
+// 异步RPC请求, ctx取消或到期时, 回调ctx.Err(), ctx没有期限时一直等待回应
+// ctx: 控制请求的取消及期限, 期限会传递给服务器,   userCallback: 返回消息或error(超时, 取消, 会话关闭)
+func CallContext(ctx context.Context, sesOrPeer interface{}, reqMsg interface{}, userCallback func(raw interface{}), opts ...CallOption) {
+
+	call(ctx, sesOrPeer, reqMsg, 0, userCallback, opts)
+}
+
+func call(ctx context.Context, sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, userCallback func(raw interface{}), opts []CallOption) {
 
 	ses, err := getPeerSession(sesOrPeer)
 
@@ -21,23 +50,33 @@ func Call(sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, user
 		return
 	}
 
-	// 发送RPC请求
-	req := createRequest(func(raw interface{}) {
+	info := newCallInfo(ctx, ses, timeout, false, opts)
+
+	// 发送RPC请求, 超时由超时调度器回调ErrTimeout
+	req := invokeClient(info, reqMsg, func(raw interface{}) {
 		cellnet.SessionQueuedCall(ses, func() {
 			userCallback(raw)
 		})
 	})
 
-	req.Send(ses, reqMsg)
+	// 请求被拦截器拦下
+	if req == nil {
+		return
+	}
 
-	// 等待RPC回复
-	time.AfterFunc(timeout, func() {
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
 
-		// 取出请求，如果存在，调用超时
-		if getRequest(req.id) != nil {
-			cellnet.SessionQueuedCall(ses, func() {
-				userCallback(ErrTimeout)
-			})
-		}
-	})
+				// 取出请求，如果存在，调用取消
+				if getRequest(ses, req.id) != nil {
+					cellnet.SessionQueuedCall(ses, func() {
+						userCallback(ctx.Err())
+					})
+				}
+			case <-req.done:
+			}
+		}()
+	}
 }
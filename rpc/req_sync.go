@@ -1,11 +1,30 @@
 package rpc
 
 import (
+	"context"
 	"time"
 )
 
 // 同步RPC请求, ud: peer/session,   reqMsg:请求用的消息, 返回消息为返回值
-func CallSync(ud interface{}, reqMsg interface{}, timeout time.Duration) (interface{}, error) {
+// timeout不大于0时立即返回ErrTimeout, 不限时的请求使用CallSyncContext
+// opts: 请求选项, 例如WithMeta
+func CallSync(ud interface{}, reqMsg interface{}, timeout time.Duration, opts ...CallOption) (interface{}, error) {
+
+	if timeout <= 0 {
+		return nil, ErrTimeout
+	}
+
+	return callSync(context.Background(), ud, reqMsg, timeout, opts)
+}
+
+// 同步RPC请求, ctx取消或到期时返回ctx.Err(), 期限会传递给服务器
+// ctx没有期限时一直等待, 直到收到回应, ctx取消或会话关闭
+func CallSyncContext(ctx context.Context, ud interface{}, reqMsg interface{}, opts ...CallOption) (interface{}, error) {
+
+	return callSync(ctx, ud, reqMsg, 0, opts)
+}
+
+func callSync(ctx context.Context, ud interface{}, reqMsg interface{}, timeout time.Duration, opts []CallOption) (interface{}, error) {
 
 	ses, err := getPeerSession(ud)
 
@@ -13,23 +32,35 @@ func CallSync(ud interface{}, reqMsg interface{}, timeout time.Duration) (interf
 		return nil, err
 	}
 
-	ret := make(chan interface{})
-	// 发送RPC请求
-	req := createRequest(func(feedbackMsg interface{}) {
-		ret <- feedbackMsg
-	})
+	// 超时与回应同时发生时, 避免阻塞接收goroutine
+	ret := make(chan interface{}, 1)
 
-	req.Send(ses, reqMsg)
+	info := newCallInfo(ctx, ses, timeout, true, opts)
+
+	// 发送RPC请求, 超时由超时调度器回调ErrTimeout
+	req := invokeClient(info, reqMsg, func(feedbackMsg interface{}) {
+		select {
+		case ret <- feedbackMsg:
+		default:
+		}
+	})
 
 	// 等待RPC回复
 	select {
 	case v := <-ret:
+
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+
 		return v, nil
-	case <-time.After(timeout):
+	case <-ctx.Done():
 
 		// 清理请求
-		getRequest(req.id)
+		if req != nil {
+			getRequest(ses, req.id)
+		}
 
-		return nil, ErrTimeout
+		return nil, ctx.Err()
 	}
 }
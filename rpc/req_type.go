@@ -2,23 +2,25 @@ package rpc
 
 import (
 	"reflect"
-	"sync"
 	"time"
 
 	"github.com/luis-quan/cellnet"
 )
 
-func CallType(sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, userCallback interface{}) {
-	callType(sesOrPeer, false, reqMsg, timeout, userCallback)
+// 异步RPC请求,按回应消息类型回调
+// 请求与回应通过CallID对应, 服务器需要使用rpc.RecvMsgEvent.Reply回应
+// 服务器直接发送回应消息(ses.Send)时, 需要在处理器中添加TypeRPCHooker, 按回应类型交给最早的请求
+func CallType(sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, userCallback interface{}, opts ...CallOption) {
+	callType(sesOrPeer, false, reqMsg, timeout, userCallback, opts)
 }
 
-func CallSyncType(sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, userCallback interface{}) {
-	callType(sesOrPeer, true, reqMsg, timeout, userCallback)
+// 同步RPC请求,按回应消息类型回调, 回调在当前goroutine执行
+func CallSyncType(sesOrPeer interface{}, reqMsg interface{}, timeout time.Duration, userCallback interface{}, opts ...CallOption) {
+	callType(sesOrPeer, true, reqMsg, timeout, userCallback, opts)
 }
 
-// 异步RPC请求,按消息类型,一般用于客户端请求
 // ud: peer/session,   reqMsg:请求用的消息, userCallback: 返回消息类型回调 func( ackMsg *ackMsgType, error )
-func callType(sesOrPeer interface{}, sync bool, reqMsg interface{}, timeout time.Duration, userCallback interface{}) {
+func callType(sesOrPeer interface{}, sync bool, reqMsg interface{}, timeout time.Duration, userCallback interface{}, opts []CallOption) {
 
 	// 获取回调第一个参数
 	funcType := reflect.TypeOf(userCallback)
@@ -35,13 +37,11 @@ func callType(sesOrPeer interface{}, sync bool, reqMsg interface{}, timeout time
 		panic("callback func param format like 'func(ack *YouMsgACK)'")
 	}
 
-	ackType = ackType.Elem()
-
 	callFunc := func(rawACK interface{}, err error) {
 		vCall := reflect.ValueOf(userCallback)
 
 		if rawACK == nil {
-			rawACK = reflect.New(ackType).Interface()
+			rawACK = reflect.New(ackType.Elem()).Interface()
 		}
 
 		var errV reflect.Value
@@ -54,74 +54,112 @@ func callType(sesOrPeer interface{}, sync bool, reqMsg interface{}, timeout time
 		vCall.Call([]reflect.Value{reflect.ValueOf(rawACK), errV})
 	}
 
+	// 回应或错误(超时, 会话关闭, 服务器回应错误)
+	onFeedback := func(feedback interface{}) {
+
+		if err, ok := feedback.(error); ok {
+			callFunc(nil, err)
+			return
+		}
+
+		if reflect.TypeOf(feedback) != ackType {
+			callFunc(nil, ErrUnexpectedReply)
+			return
+		}
+
+		callFunc(feedback, nil)
+	}
+
 	ses, err := getPeerSession(sesOrPeer)
 
+	opts = append(opts[:len(opts):len(opts)], withAckType(ackType))
+
 	if err != nil {
 		callFunc(nil, err)
 		return
 	}
 
-	createTypeRequest(sync, ackType, timeout, func() {
-		ses.Send(reqMsg)
-	}, callFunc)
+	if sync {
+		ack, err := CallSync(ses, reqMsg, timeout, opts...)
+		if err != nil {
+			onFeedback(err)
+		} else {
+			onFeedback(ack)
+		}
+	} else {
+		Call(ses, reqMsg, timeout, onFeedback, opts...)
+	}
+}
 
+// 记录回应类型, 用于TypeRPCHooker对应直接发送的回应
+func withAckType(ackType reflect.Type) CallOption {
+	return func(info *CallInfo) {
+		info.ackType = ackType
+	}
 }
 
 var (
-	nilError   = reflect.Zero(reflect.TypeOf((*error)(nil)).Elem())
-	callByType sync.Map // map[reflect.Type]func(interface{})
+	nilError = reflect.Zero(reflect.TypeOf((*error)(nil)).Elem())
 )
 
-func createTypeRequest(sync bool, ackType reflect.Type, timeout time.Duration, onSend func(), onRecv func(rawACK interface{}, err error)) {
-
-	if sync {
-		feedBack := make(chan interface{})
-		callByType.Store(ackType, feedBack)
-
-		defer callByType.Delete(ackType)
-
-		onSend()
-
-		select {
-		case ack := <-feedBack:
-			onRecv(ack, nil)
-		case <-time.After(timeout):
-			onRecv(nil, ErrTimeout)
-		}
-	} else {
-
-		callByType.Store(ackType, func(rawACK interface{}, err error) {
-			onRecv(rawACK, err)
-			callByType.Delete(ackType)
-		})
+// 兼容服务器直接发送回应消息(ses.Send)的类型RPC, 放在处理器的Hooker之后
+// 前面的Hooker不处理RPC时(例如gorillaws.MsgHooker), 在这里处理RPC请求及回应
+type TypeRPCHooker struct {
+}
 
-		onSend()
+func (TypeRPCHooker) OnInboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event) {
 
-		// 丢弃超时的类型,避免重复请求时,将第二次请求的消息删除
+	// 已经处理过的请求
+	switch inputEvent.(type) {
+	case *RecvMsgEvent, *RecvStreamEvent:
+		return inputEvent
 	}
 
-}
+	ses := inputEvent.Session()
 
-type TypeRPCHooker struct {
-}
+	switch msg := inputEvent.Message().(type) {
+	case nil:
+		return inputEvent
+	case *cellnet.SessionClosed:
+		failSessionRequests(ses)
+		return inputEvent
+	case *RemoteCallACK:
 
-func (TypeRPCHooker) OnInboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event) {
+		// 前面的Hooker已经处理
+		if calls := getSessionCalls(ses, false); calls == nil || !calls.has(msg.CallID) {
+			return inputEvent
+		}
 
-	incomingMsgType := reflect.TypeOf(inputEvent.Message()).Elem()
+		return resolveTypeRPC(inputEvent)
+	case *RemoteCallREQ:
+		return resolveTypeRPC(inputEvent)
+	default:
 
-	if rawFeedback, ok := callByType.Load(incomingMsgType); ok {
+		// 直接发送的回应, 交给回应类型相同的最早的请求
+		calls := getSessionCalls(ses, false)
+		if calls == nil {
+			return inputEvent
+		}
 
-		switch feedBack := rawFeedback.(type) {
-		case func(rawACK interface{}, err error):
-			feedBack(inputEvent.Message(), nil)
-		case chan interface{}:
-			feedBack <- inputEvent.Message()
+		if id := calls.oldestByType(reflect.TypeOf(msg)); id != 0 {
+			if req := getRequest(ses, id); req != nil {
+				req.RecvFeedback(msg)
+			}
 		}
 
 		return inputEvent
 	}
+}
+
+func resolveTypeRPC(inputEvent cellnet.Event) cellnet.Event {
+
+	outputEvent, _, err := ResolveInboundEvent(inputEvent)
+	if err != nil {
+		log.Errorln("rpc.ResolveInboundEvent:", err)
+		return nil
+	}
 
-	return inputEvent
+	return outputEvent
 }
 
 func (TypeRPCHooker) OnOutboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event) {
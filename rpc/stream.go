@@ -0,0 +1,505 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
+)
+
+// 流帧类型
+type StreamFrameKind int32
+
+const (
+	StreamFrame_Open   StreamFrameKind = iota // 打开流, 携带请求消息及发起方的接收窗口
+	StreamFrame_Data                          // 流上的一个消息
+	StreamFrame_Credit                        // 归还发送额度
+	StreamFrame_End                           // 发送方结束发送(半关闭), Code非0时携带错误
+	StreamFrame_Cancel                        // 取消流, 双方立即结束, Code非0时携带错误
+)
+
+const (
+	// 接收窗口, 对端最多可以发送这么多未被Recv取走的消息, 超过时以StatusCode_ResourceExhausted结束流
+	streamWindow = 32
+
+	// 每个会话上对端同时打开的流的上限, 超过时以StatusCode_ResourceExhausted拒绝
+	maxInboundStreams = 64
+)
+
+var (
+	ErrStreamClosed   = errors.New("rpc: send on closed stream")
+	ErrTooManyStreams = errors.New("rpc: too many inbound streams")
+)
+
+// 流在会话内的标识, 双方各自分配StreamID, 用发起方区分
+type streamKey struct {
+	id        int64
+	initiator bool // 本端是否为发起方
+}
+
+// 在会话上复用的双向消息流
+// Send/Recv可以在不同goroutine中同时调用, Recv会阻塞, 不要在事件队列中调用
+type Stream struct {
+	ses       cellnet.Session
+	id        int64
+	initiator bool
+
+	guard sync.Mutex
+	cond  *sync.Cond
+
+	recvList []interface{}
+	recvUsed int32 // 已经被Recv取走, 还未归还给对端的额度
+	recvEnd  bool  // 对端已经结束发送
+	recvErr  error // 对端结束发送时附带的错误
+
+	sendCredit int32 // 对端允许继续发送的数量
+	sendEnd    bool  // 本端已经结束发送
+
+	err error // 流被取消或会话断开
+}
+
+func (self *Stream) ID() int64 {
+	return self.id
+}
+
+func (self *Stream) Session() cellnet.Session {
+	return self.ses
+}
+
+// 发送消息, 对端接收窗口满时阻塞
+func (self *Stream) Send(msg interface{}) error {
+
+	data, meta, err := codec.EncodeMessage(msg, nil)
+
+	if err != nil {
+		return err
+	}
+
+	self.guard.Lock()
+
+	for self.sendCredit <= 0 && self.err == nil && !self.sendEnd {
+		self.cond.Wait()
+	}
+
+	switch {
+	case self.err != nil:
+		err = self.err
+	case self.sendEnd:
+		err = ErrStreamClosed
+	default:
+		self.sendCredit--
+	}
+
+	self.guard.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	self.sendFrame(&RemoteStreamFrame{
+		Kind:  int32(StreamFrame_Data),
+		MsgID: uint32(meta.ID),
+		Data:  data,
+	})
+
+	return nil
+}
+
+// 接收消息, 对端正常结束时返回io.EOF, 对端以错误结束时返回*CallError
+func (self *Stream) Recv() (interface{}, error) {
+
+	self.guard.Lock()
+
+	for {
+		if len(self.recvList) > 0 {
+			msg := self.recvList[0]
+			self.recvList[0] = nil
+			self.recvList = self.recvList[1:]
+
+			// 取走一半窗口时归还额度
+			var credit int32
+			self.recvUsed++
+			if self.recvUsed >= streamWindow/2 && !self.recvEnd && self.err == nil {
+				credit = self.recvUsed
+				self.recvUsed = 0
+			}
+
+			self.guard.Unlock()
+
+			if credit > 0 {
+				self.sendFrame(&RemoteStreamFrame{
+					Kind:   int32(StreamFrame_Credit),
+					Credit: credit,
+				})
+			}
+
+			return msg, nil
+		}
+
+		if self.err != nil {
+			defer self.guard.Unlock()
+			return nil, self.err
+		}
+
+		if self.recvEnd {
+			defer self.guard.Unlock()
+
+			if self.recvErr != nil {
+				return nil, self.recvErr
+			}
+
+			return nil, io.EOF
+		}
+
+		self.cond.Wait()
+	}
+}
+
+// 结束发送(半关闭), 仍然可以继续Recv直到对端结束
+func (self *Stream) Close() error {
+	return self.closeSend(StatusCode_OK, "")
+}
+
+// 以错误结束发送, 对端Recv返回*CallError
+func (self *Stream) CloseWithError(code StatusCode, msg string) error {
+
+	// OK不是错误
+	if code == StatusCode_OK {
+		code = StatusCode_Unknown
+	}
+
+	return self.closeSend(code, msg)
+}
+
+func (self *Stream) closeSend(code StatusCode, msg string) error {
+
+	self.guard.Lock()
+
+	if self.err != nil {
+		defer self.guard.Unlock()
+		return self.err
+	}
+
+	if self.sendEnd {
+		self.guard.Unlock()
+		return nil
+	}
+
+	self.sendEnd = true
+	done := self.recvEnd
+	self.cond.Broadcast()
+	self.guard.Unlock()
+
+	self.sendFrame(&RemoteStreamFrame{
+		Kind:  int32(StreamFrame_End),
+		Code:  int32(code),
+		Error: msg,
+	})
+
+	if done {
+		self.remove()
+	}
+
+	return nil
+}
+
+// 取消流, 双方阻塞的Send/Recv返回ErrCanceled
+func (self *Stream) Cancel() {
+
+	if !self.fail(ErrCanceled) {
+		return
+	}
+
+	self.sendFrame(&RemoteStreamFrame{
+		Kind: int32(StreamFrame_Cancel),
+	})
+
+	self.remove()
+}
+
+// 以错误结束流, 已经结束时返回false
+func (self *Stream) fail(err error) bool {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	if self.err != nil {
+		return false
+	}
+
+	self.err = err
+	self.cond.Broadcast()
+
+	return true
+}
+
+// 以错误结束流, 并通知对端
+func (self *Stream) abort(code StatusCode, msg string) error {
+
+	err := &CallError{Code: code, Msg: msg}
+
+	if !self.fail(err) {
+		return err
+	}
+
+	self.sendFrame(&RemoteStreamFrame{
+		Kind:  int32(StreamFrame_Cancel),
+		Code:  int32(code),
+		Error: msg,
+	})
+
+	self.remove()
+
+	return err
+}
+
+func (self *Stream) sendFrame(frame *RemoteStreamFrame) {
+
+	frame.StreamID = self.id
+	frame.Initiator = self.initiator
+
+	self.ses.Send(frame)
+}
+
+func (self *Stream) remove() {
+
+	calls := getSessionCalls(self.ses, false)
+	if calls == nil {
+		return
+	}
+
+	key := streamKey{self.id, self.initiator}
+
+	calls.guard.Lock()
+	if calls.streamByKey[key] == self {
+		delete(calls.streamByKey, key)
+	}
+	calls.guard.Unlock()
+}
+
+// 处理对端发来的帧
+func (self *Stream) onFrame(frame *RemoteStreamFrame) error {
+
+	self.guard.Lock()
+
+	done := false
+
+	switch StreamFrameKind(frame.Kind) {
+	case StreamFrame_Data:
+
+		// 对端没有遵守额度, 未取走及未归还的消息已经占满窗口
+		if int32(len(self.recvList))+self.recvUsed >= streamWindow {
+			self.guard.Unlock()
+			return self.abort(StatusCode_ResourceExhausted, "stream receive window exceeded")
+		}
+
+		msg, _, err := codec.DecodeMessage(int(frame.MsgID), frame.Data)
+		if err != nil {
+			self.guard.Unlock()
+			return err
+		}
+
+		self.recvList = append(self.recvList, msg)
+	case StreamFrame_Credit:
+		self.sendCredit += frame.Credit
+	case StreamFrame_End:
+		self.recvEnd = true
+
+		if frame.Code != int32(StatusCode_OK) {
+			self.recvErr = &CallError{
+				Code: StatusCode(frame.Code),
+				Msg:  frame.Error,
+			}
+		}
+
+		done = self.sendEnd
+	case StreamFrame_Cancel:
+		if self.err == nil {
+			if frame.Code != int32(StatusCode_OK) {
+				self.err = &CallError{
+					Code: StatusCode(frame.Code),
+					Msg:  frame.Error,
+				}
+			} else {
+				self.err = ErrCanceled
+			}
+		}
+
+		done = true
+	}
+
+	self.cond.Broadcast()
+	self.guard.Unlock()
+
+	if done {
+		self.remove()
+	}
+
+	return nil
+}
+
+func newStream(ses cellnet.Session, id int64, initiator bool) *Stream {
+
+	self := &Stream{
+		ses:       ses,
+		id:        id,
+		initiator: initiator,
+	}
+
+	self.cond = sync.NewCond(&self.guard)
+
+	return self
+}
+
+// 打开流, reqMsg随打开帧发送, 服务器收到*RecvStreamEvent
+// 服务器流: 打开后调用Close结束发送, 再循环Recv直到io.EOF
+func OpenStream(sesOrPeer interface{}, reqMsg interface{}) (*Stream, error) {
+
+	ses, err := getPeerSession(sesOrPeer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, meta, err := codec.EncodeMessage(reqMsg, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	calls := getSessionCalls(ses, true)
+
+	calls.guard.Lock()
+	calls.streamSeq++
+	stream := newStream(ses, calls.streamSeq, true)
+	calls.streamByKey[streamKey{stream.id, true}] = stream
+	calls.guard.Unlock()
+
+	stream.sendFrame(&RemoteStreamFrame{
+		Kind:   int32(StreamFrame_Open),
+		MsgID:  uint32(meta.ID),
+		Data:   data,
+		Credit: streamWindow,
+	})
+
+	return stream, nil
+}
+
+// 处理收到的流帧, 收到打开帧时返回*RecvStreamEvent
+func resolveStreamFrame(inputEvent cellnet.Event, frame *RemoteStreamFrame) (cellnet.Event, error) {
+
+	ses := inputEvent.Session()
+
+	if StreamFrameKind(frame.Kind) == StreamFrame_Open {
+
+		msg, _, err := codec.DecodeMessage(int(frame.MsgID), frame.Data)
+		if err != nil {
+			return inputEvent, err
+		}
+
+		stream := newStream(ses, frame.StreamID, false)
+		stream.sendCredit = frame.Credit
+
+		calls := getSessionCalls(ses, true)
+
+		calls.guard.Lock()
+
+		if calls.inboundStreamCount() >= maxInboundStreams {
+			calls.guard.Unlock()
+
+			// 流没有加入会话, 发起方收到取消帧后结束
+			stream.sendFrame(&RemoteStreamFrame{
+				Kind:  int32(StreamFrame_Cancel),
+				Code:  int32(StatusCode_ResourceExhausted),
+				Error: "too many streams",
+			})
+
+			return inputEvent, ErrTooManyStreams
+		}
+
+		calls.streamByKey[streamKey{stream.id, false}] = stream
+		calls.guard.Unlock()
+
+		// 允许发起方发送
+		stream.sendFrame(&RemoteStreamFrame{
+			Kind:   int32(StreamFrame_Credit),
+			Credit: streamWindow,
+		})
+
+		return &RecvStreamEvent{
+			ses:    ses,
+			Msg:    msg,
+			Stream: stream,
+		}, nil
+	}
+
+	calls := getSessionCalls(ses, false)
+	if calls == nil {
+		return inputEvent, nil
+	}
+
+	// 对端发起的流, 本端不是发起方
+	calls.guard.Lock()
+	stream := calls.streamByKey[streamKey{frame.StreamID, !frame.Initiator}]
+	calls.guard.Unlock()
+
+	// 流已经结束
+	if stream == nil {
+		return inputEvent, nil
+	}
+
+	return inputEvent, stream.onFrame(frame)
+}
+
+// 对端打开的流的数量, 在guard内调用
+func (self *sessionCalls) inboundStreamCount() (ret int) {
+
+	for key := range self.streamByKey {
+		if !key.initiator {
+			ret++
+		}
+	}
+
+	return
+}
+
+// 取出会话上所有的流
+func takeSessionStreams(ses cellnet.Session) (ret []*Stream) {
+
+	calls := getSessionCalls(ses, false)
+	if calls == nil {
+		return
+	}
+
+	calls.guard.Lock()
+	for key, stream := range calls.streamByKey {
+		ret = append(ret, stream)
+		delete(calls.streamByKey, key)
+	}
+	calls.guard.Unlock()
+
+	return
+}
+
+// 服务器收到打开流的请求
+type RecvStreamEvent struct {
+	ses    cellnet.Session
+	Msg    interface{} // 打开流时的请求消息
+	Stream *Stream
+}
+
+func (self *RecvStreamEvent) Session() cellnet.Session {
+	return self.ses
+}
+
+func (self *RecvStreamEvent) Message() interface{} {
+	return self.Msg
+}
+
+func (self *RecvStreamEvent) Queue() cellnet.EventQueue {
+	return self.ses.Peer().(interface {
+		Queue() cellnet.EventQueue
+	}).Queue()
+}
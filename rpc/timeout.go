@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/luis-quan/cellnet/timer"
+)
+
+// 所有请求共用的超时调度器, 只为最早到期的请求保留一个定时器
+type timeoutScheduler struct {
+	guard sync.Mutex
+
+	reqList timeoutHeap
+
+	timer      timer.AfterStopper
+	timerClock timer.Clock
+	armed      time.Time
+}
+
+var timeoutSched timeoutScheduler
+
+func (self *timeoutScheduler) add(req *request) {
+
+	self.guard.Lock()
+	heap.Push(&self.reqList, req)
+	self.rearm()
+	self.guard.Unlock()
+}
+
+func (self *timeoutScheduler) remove(req *request) {
+
+	self.guard.Lock()
+
+	if req.timeoutIndex >= 0 {
+		heap.Remove(&self.reqList, req.timeoutIndex)
+	}
+
+	if len(self.reqList) == 0 && self.timer != nil {
+		self.timer.Stop()
+		self.timer = nil
+	}
+
+	self.guard.Unlock()
+}
+
+// 按最早到期的请求设置定时器, 需要在锁内调用
+func (self *timeoutScheduler) rearm() {
+
+	if len(self.reqList) == 0 {
+		return
+	}
+
+	deadline := self.reqList[0].deadline
+
+	clock := timer.CurrentClock()
+
+	// 已有更早的定时器
+	if self.timer != nil && self.timerClock == clock && !deadline.Before(self.armed) {
+		return
+	}
+
+	if self.timer != nil {
+		self.timer.Stop()
+	}
+
+	self.armed = deadline
+	self.timerClock = clock
+	self.timer = clock.AfterFunc(deadline.Sub(clock.Now()), self.onTimer)
+}
+
+func (self *timeoutScheduler) onTimer() {
+
+	now := timer.Now()
+
+	var expired []*request
+
+	self.guard.Lock()
+
+	self.timer = nil
+
+	for len(self.reqList) > 0 && !self.reqList[0].deadline.After(now) {
+		expired = append(expired, heap.Pop(&self.reqList).(*request))
+	}
+
+	self.rearm()
+
+	self.guard.Unlock()
+
+	for _, req := range expired {
+
+		// 取出请求，如果存在，调用超时
+		if getRequest(req.ses, req.id) != nil {
+			req.RecvFeedback(ErrTimeout)
+		}
+	}
+}
+
+type timeoutHeap []*request
+
+func (self timeoutHeap) Len() int { return len(self) }
+
+func (self timeoutHeap) Less(i, j int) bool {
+	return self[i].deadline.Before(self[j].deadline)
+}
+
+func (self timeoutHeap) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+	self[i].timeoutIndex = i
+	self[j].timeoutIndex = j
+}
+
+func (self *timeoutHeap) Push(x interface{}) {
+	req := x.(*request)
+	req.timeoutIndex = len(*self)
+	*self = append(*self, req)
+}
+
+func (self *timeoutHeap) Pop() interface{} {
+	old := *self
+	n := len(old)
+	req := old[n-1]
+	old[n-1] = nil
+	req.timeoutIndex = -1
+	*self = old[:n-1]
+	return req
+}
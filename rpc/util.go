@@ -9,6 +9,7 @@ import (
 var (
 	ErrInvalidPeerSession = errors.New("rpc: Invalid peer type, require cellnet.RPCSessionGetter or cellnet.Session")
 	ErrEmptySession       = errors.New("rpc: Empty session")
+	ErrUnexpectedReply    = errors.New("rpc: Unexpected reply message type")
 )
 
 type RPCSessionGetter interface {
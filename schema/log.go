@@ -0,0 +1,7 @@
+package schema
+
+import (
+	"github.com/davyxu/golog"
+)
+
+var log = golog.New("schema")
@@ -0,0 +1,73 @@
+// Generated by github.com/luis-quan/protoplus
+// DO NOT EDIT!
+package schema
+
+import (
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
+	_ "github.com/luis-quan/cellnet/codec/protoplus"
+	"github.com/davyxu/protoplus/proto"
+	"reflect"
+	"unsafe"
+)
+
+var (
+	_ *proto.Buffer
+	_ codec.CodecRecycler
+	_ cellnet.Session
+	_ reflect.Type
+	_ unsafe.Pointer
+)
+
+type SchemaHandshake struct {
+	Version   uint32 // 用户指定的协议版本
+	MetaHash  uint64 // 消息元信息的哈希
+	MetaCount int32  // 参与哈希的消息数量
+}
+
+func (self *SchemaHandshake) String() string { return proto.CompactTextString(self) }
+
+func (self *SchemaHandshake) Size() (ret int) {
+
+	ret += proto.SizeUInt32(0, self.Version)
+
+	ret += proto.SizeUInt64(1, self.MetaHash)
+
+	ret += proto.SizeInt32(2, self.MetaCount)
+
+	return
+}
+
+func (self *SchemaHandshake) Marshal(buffer *proto.Buffer) error {
+
+	proto.MarshalUInt32(buffer, 0, self.Version)
+
+	proto.MarshalUInt64(buffer, 1, self.MetaHash)
+
+	proto.MarshalInt32(buffer, 2, self.MetaCount)
+
+	return nil
+}
+
+func (self *SchemaHandshake) Unmarshal(buffer *proto.Buffer, fieldIndex uint64, wt proto.WireType) error {
+	switch fieldIndex {
+	case 0:
+		return proto.UnmarshalUInt32(buffer, wt, &self.Version)
+	case 1:
+		return proto.UnmarshalUInt64(buffer, wt, &self.MetaHash)
+	case 2:
+		return proto.UnmarshalInt32(buffer, wt, &self.MetaCount)
+
+	}
+
+	return proto.ErrUnknownField
+}
+
+func init() {
+
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("protoplus"),
+		Type:  reflect.TypeOf((*SchemaHandshake)(nil)).Elem(),
+		ID:    38359,
+	})
+}
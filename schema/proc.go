@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/timer"
+)
+
+// 处理入站事件, 启用握手的Peer在握手完成前暂缓连接事件并丢弃用户消息, 握手通过后投递连接事件
+func ResolveInboundEvent(inputEvent cellnet.Event) (outputEvent cellnet.Event, err error) {
+
+	ses := inputEvent.Session()
+	if ses == nil {
+		return inputEvent, nil
+	}
+
+	ps := peerStateOf(ses.Peer())
+	if ps == nil {
+		return inputEvent, nil
+	}
+
+	switch msg := inputEvent.Message().(type) {
+	case *cellnet.SessionAccepted, *cellnet.SessionConnected:
+
+		st := sessionStateOf(ses, true)
+		if st == nil {
+			return inputEvent, nil
+		}
+
+		st.guard.Lock()
+
+		// 上一次连接的状态
+		if st.started || st.closed {
+			st.reset()
+		}
+
+		st.started = true
+		st.pending = msg
+
+		gen := st.gen
+		st.timeout = timer.CurrentClock().AfterFunc(ps.opt.Timeout, func() {
+
+			st.guard.Lock()
+			expired := st.gen == gen && !st.done && !st.rejected && !st.closed
+			if expired {
+				st.rejected = true
+			}
+			st.guard.Unlock()
+
+			if expired {
+				log.Warnf("schema handshake timeout, session: %d", ses.ID())
+				cellnet.CloseSession(ses, cellnet.CloseReason_Incompatible, 0)
+			}
+		})
+
+		// 对方的握手先于连接事件到达
+		var pending interface{}
+		early := st.early
+		st.early = nil
+
+		if early != nil {
+			pending, err = st.resolve(ses, ps, early)
+		}
+
+		st.guard.Unlock()
+
+		ses.Send(ps.local)
+
+		if early == nil {
+			return nil, nil
+		}
+
+		return onResolved(ses, ps, early, pending, err)
+
+	case *SchemaHandshake:
+
+		st := sessionStateOf(ses, true)
+		if st == nil {
+			return nil, nil
+		}
+
+		st.guard.Lock()
+
+		if st.closed {
+			st.reset()
+		}
+
+		// 重复的握手消息或已经拒绝
+		if st.done || st.rejected || st.early != nil {
+			st.guard.Unlock()
+			return nil, nil
+		}
+
+		// 会话启动后, 连接事件投递前收到, 等待连接事件时处理
+		if !st.started {
+			st.early = msg
+			st.guard.Unlock()
+			return nil, nil
+		}
+
+		pending, err := st.resolve(ses, ps, msg)
+		st.guard.Unlock()
+
+		return onResolved(ses, ps, msg, pending, err)
+
+	case *cellnet.SessionClosed:
+
+		st := sessionStateOf(ses, false)
+		if st == nil {
+			return inputEvent, nil
+		}
+
+		st.guard.Lock()
+		st.stopTimeout()
+		st.closed = true
+		done := st.done
+		st.guard.Unlock()
+
+		// 用户没有收到连接事件, 也不通知关闭
+		if !done {
+			return nil, nil
+		}
+
+	case *cellnet.SessionConnectError, *cellnet.SessionCloseNotify, *cellnet.SessionInit:
+
+	default:
+
+		var done bool
+		if st := sessionStateOf(ses, false); st != nil {
+			st.guard.Lock()
+			done = st.done
+			st.guard.Unlock()
+		}
+
+		if !done {
+			log.Warnf("drop message before schema handshake, session: %d, msg: %s", ses.ID(), cellnet.MessageToName(msg))
+			return nil, nil
+		}
+	}
+
+	return inputEvent, nil
+}
+
+// 握手通过时投递暂缓的连接事件, 拒绝时断开
+func onResolved(ses cellnet.Session, ps *peerState, remote *SchemaHandshake, pending interface{}, err error) (cellnet.Event, error) {
+
+	if err != nil {
+		log.Warnf("schema handshake rejected, session: %d, local: %+v, remote: %+v, err: %s", ses.ID(), ps.local, remote, err)
+		cellnet.CloseSession(ses, cellnet.CloseReason_Incompatible, 0)
+		return nil, nil
+	}
+
+	return &cellnet.RecvMsgEvent{Ses: ses, Msg: pending}, nil
+}
@@ -0,0 +1,265 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/timer"
+)
+
+// 等待对方握手消息的默认时间
+const DefaultTimeout = time.Second * 10
+
+var (
+	ErrVersionMismatch = errors.New("schema: protocol version mismatch")
+	ErrMetaMismatch    = errors.New("schema: message meta mismatch")
+)
+
+// 收到对方的握手消息后调用, 返回error时拒绝对方并断开连接, 断开原因为CloseReason_Incompatible
+// 可以根据对方的版本在会话上设置兼容标记, 之后按标记收发旧版本的消息
+type Policy func(ses cellnet.Session, local, remote *SchemaHandshake) error
+
+// 版本不同时拒绝, 消息元信息不同时只记录警告
+func DefaultPolicy(ses cellnet.Session, local, remote *SchemaHandshake) error {
+
+	if local.Version != remote.Version {
+		return ErrVersionMismatch
+	}
+
+	if local.MetaHash != remote.MetaHash {
+		log.Warnf("message meta mismatch, session: %d, local: %x(%d), remote: %x(%d)",
+			ses.ID(), local.MetaHash, local.MetaCount, remote.MetaHash, remote.MetaCount)
+	}
+
+	return nil
+}
+
+// 版本或消息元信息不同时都拒绝
+func StrictPolicy(ses cellnet.Session, local, remote *SchemaHandshake) error {
+
+	if local.Version != remote.Version {
+		return ErrVersionMismatch
+	}
+
+	if local.MetaHash != remote.MetaHash {
+		return ErrMetaMismatch
+	}
+
+	return nil
+}
+
+// 握手参数, 为0的字段使用默认值
+type Option struct {
+	Version  uint32        // 用户指定的协议版本
+	HashRule string        // 参与哈希的消息全名正则表达式, 例如"^proto\\.", 为空时包含所有消息
+	Policy   Policy        // 为nil时使用DefaultPolicy
+	Timeout  time.Duration // 等待对方握手消息的时间, 超时断开
+}
+
+type (
+	peerKey    struct{}
+	sessionKey struct{}
+)
+
+type peerState struct {
+	opt   Option
+	local *SchemaHandshake
+}
+
+var peerGuard sync.Mutex
+
+// 为Peer启用连接握手, 双方都需要启用, 在Start之前且所有消息注册完成后调用
+// 连接建立后双方交换SchemaHandshake, 策略通过后才收到SessionAccepted/SessionConnected
+func Enable(p cellnet.Peer, opt Option) error {
+
+	if opt.Policy == nil {
+		opt.Policy = DefaultPolicy
+	}
+
+	if opt.Timeout <= 0 {
+		opt.Timeout = DefaultTimeout
+	}
+
+	hash, count, err := MetaHash(opt.HashRule)
+	if err != nil {
+		return err
+	}
+
+	ps := &peerState{
+		opt: opt,
+		local: &SchemaHandshake{
+			Version:   opt.Version,
+			MetaHash:  hash,
+			MetaCount: int32(count),
+		},
+	}
+
+	peerGuard.Lock()
+	p.(cellnet.ContextSet).SetContext(peerKey{}, ps)
+	peerGuard.Unlock()
+
+	return nil
+}
+
+// 本端发送的握手消息, 没有启用时返回nil
+func Local(p cellnet.Peer) *SchemaHandshake {
+
+	if ps := peerStateOf(p); ps != nil {
+		return ps.local
+	}
+
+	return nil
+}
+
+// 握手完成后, 对方的握手消息
+func RemoteOf(ses cellnet.Session) (*SchemaHandshake, bool) {
+
+	st := sessionStateOf(ses, false)
+	if st == nil {
+		return nil, false
+	}
+
+	st.guard.Lock()
+	defer st.guard.Unlock()
+
+	if !st.done {
+		return nil, false
+	}
+
+	return st.remote, true
+}
+
+// 计算名称符合nameRule的消息元信息的哈希, 包含消息ID, 全名, 编码及字段的名称和类型
+// 双方注册的消息不同, 或者同一个消息的字段不同时, 哈希不同
+func MetaHash(nameRule string) (hash uint64, count int, err error) {
+
+	infos, err := cellnet.MessageMetaInfos(nameRule)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	h := fnv.New64a()
+
+	for _, info := range infos {
+
+		fmt.Fprintf(h, "%d:%s:%s", info.ID, info.FullName, info.Codec)
+
+		for _, field := range info.Fields {
+			fmt.Fprintf(h, ":%s %s", field.Name, field.Type)
+		}
+
+		h.Write([]byte{'\n'})
+	}
+
+	return h.Sum64(), len(infos), nil
+}
+
+func peerStateOf(p cellnet.Peer) *peerState {
+
+	ctxSet, ok := p.(cellnet.ContextSet)
+	if !ok {
+		return nil
+	}
+
+	peerGuard.Lock()
+	defer peerGuard.Unlock()
+
+	if raw, ok := ctxSet.GetContext(peerKey{}); ok {
+		return raw.(*peerState)
+	}
+
+	return nil
+}
+
+// 会话上的握手状态, 连接器重连后会话不变, 新连接时重置
+type sessionState struct {
+	guard sync.Mutex
+
+	gen uint64 // 连接序号, 重置时递增, 避免上次连接的超时关闭新连接
+
+	started  bool // 收到连接事件
+	closed   bool // 收到关闭事件
+	done     bool
+	rejected bool
+
+	pending interface{}      // 握手完成前暂缓投递的SessionAccepted/SessionConnected
+	early   *SchemaHandshake // 连接事件投递前收到的对方握手
+	remote  *SchemaHandshake
+
+	timeout timer.AfterStopper
+}
+
+// 调用时需要加锁
+func (self *sessionState) stopTimeout() {
+	if self.timeout != nil {
+		self.timeout.Stop()
+		self.timeout = nil
+	}
+}
+
+// 调用时需要加锁
+func (self *sessionState) reset() {
+
+	self.stopTimeout()
+
+	self.gen++
+	self.started = false
+	self.closed = false
+	self.done = false
+	self.rejected = false
+	self.pending = nil
+	self.early = nil
+	self.remote = nil
+}
+
+// 按策略检查对方的握手, 通过时返回暂缓的连接事件, 调用时需要加锁
+func (self *sessionState) resolve(ses cellnet.Session, ps *peerState, remote *SchemaHandshake) (pending interface{}, err error) {
+
+	self.stopTimeout()
+
+	if err = ps.opt.Policy(ses, ps.local, remote); err != nil {
+		self.rejected = true
+		return nil, err
+	}
+
+	self.done = true
+	self.remote = remote
+	pending = self.pending
+	self.pending = nil
+
+	return pending, nil
+}
+
+// 会话的握手状态, create为true时没有则创建
+// 会话启动后才投递连接事件, 接收循环可能先收到对方的握手, 两者在不同的goroutine, 需要原子地创建
+func sessionStateOf(ses cellnet.Session, create bool) *sessionState {
+
+	if ses == nil {
+		return nil
+	}
+
+	ctxSet, ok := ses.(cellnet.ContextSet)
+	if !ok {
+		return nil
+	}
+
+	peerGuard.Lock()
+	defer peerGuard.Unlock()
+
+	if raw, ok := ctxSet.GetContext(sessionKey{}); ok {
+		return raw.(*sessionState)
+	}
+
+	if !create {
+		return nil
+	}
+
+	st := &sessionState{}
+	ctxSet.SetContext(sessionKey{}, st)
+
+	return st
+}
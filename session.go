@@ -1,5 +1,7 @@
 package cellnet
 
+import "time"
+
 // 长连接
 type Session interface {
 
@@ -19,6 +21,33 @@ type Session interface {
 	ID() int64
 }
 
+// 支持带原因关闭的会话
+type SessionReasonCloser interface {
+
+	// 发送关闭帧后断开, 对方的SessionClosed中包含原因及关闭码
+	CloseWithReason(reason CloseReason, code int32)
+}
+
+// 支持发送完成后关闭的会话
+type SessionFlushCloser interface {
+
+	// 发送队列中的消息全部发出, 对方断开或超时后关闭
+	CloseAfterFlush(timeout time.Duration)
+
+	// 发送msg后关闭, 例如断开前通知客户端被踢的原因
+	CloseWithMessage(msg interface{})
+}
+
+// 带原因关闭会话, 会话不支持关闭帧时直接关闭
+func CloseSession(ses Session, reason CloseReason, code int32) {
+
+	if closer, ok := ses.(SessionReasonCloser); ok {
+		closer.CloseWithReason(reason, code)
+	} else {
+		ses.Close()
+	}
+}
+
 // 直接发送数据时，将*RawPacket作为Send参数
 type RawPacket struct {
 	MsgData []byte
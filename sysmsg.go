@@ -17,8 +17,16 @@ type SessionConnectError struct {
 type CloseReason int32
 
 const (
-	CloseReason_IO     CloseReason = iota // 普通IO断开
-	CloseReason_Manual                    // 关闭前，调用过Session.Close
+	CloseReason_IO                CloseReason = iota // 普通IO断开
+	CloseReason_Manual                               // 关闭前，调用过Session.Close
+	CloseReason_ReadTimeout                          // 读取超时
+	CloseReason_MaxPacketExceeded                    // 封包超过最大长度
+	CloseReason_DecodeError                          // 消息解码失败
+	CloseReason_PeerStopping                         // Peer停止时关闭所有会话
+	CloseReason_HeartbeatTimeout                     // 心跳超时
+	CloseReason_Kicked                               // 被踢下线, 例如重复登录
+	CloseReason_Incompatible                         // 连接握手时双方协议不兼容
+	CloseReason_UnackedOverflow                      // 等待对方确认的消息超过上限, 无法保证送达
 )
 
 func (self CloseReason) String() string {
@@ -27,13 +35,65 @@ func (self CloseReason) String() string {
 		return "IO"
 	case CloseReason_Manual:
 		return "Manual"
+	case CloseReason_ReadTimeout:
+		return "ReadTimeout"
+	case CloseReason_MaxPacketExceeded:
+		return "MaxPacketExceeded"
+	case CloseReason_DecodeError:
+		return "DecodeError"
+	case CloseReason_PeerStopping:
+		return "PeerStopping"
+	case CloseReason_HeartbeatTimeout:
+		return "HeartbeatTimeout"
+	case CloseReason_Kicked:
+		return "Kicked"
+	case CloseReason_Incompatible:
+		return "Incompatible"
+	case CloseReason_UnackedOverflow:
+		return "UnackedOverflow"
+	}
+
+	return "Unknown"
+}
+
+// 发起关闭的一方
+type CloseInitiator int32
+
+const (
+	CloseInitiator_Unknown CloseInitiator = iota // 无法判断, 例如网络中断
+	CloseInitiator_Local                         // 本端关闭
+	CloseInitiator_Remote                        // 对方关闭
+)
+
+func (self CloseInitiator) String() string {
+	switch self {
+	case CloseInitiator_Local:
+		return "Local"
+	case CloseInitiator_Remote:
+		return "Remote"
 	}
 
 	return "Unknown"
 }
 
 type SessionClosed struct {
-	Reason CloseReason // 断开原因
+	Reason    CloseReason    // 断开原因, 对方通过关闭帧告知时为对方的原因
+	Initiator CloseInitiator // 发起关闭的一方
+	Code      int32          // 用户指定的关闭码, 随关闭帧发给对方
+	Err       error          // 导致断开的错误, 主动关闭时为nil
+}
+
+// 主动关闭时发给对方的关闭帧, 对方的SessionClosed中可以获得原因及关闭码, 内部使用
+type SessionCloseFrame struct {
+	Reason CloseReason
+	Code   int32
+}
+
+// 消息解码失败或收到未注册的消息, 按Peer的解码错误策略投递
+type DecodeError struct {
+	MsgID int   // 消息ID
+	Size  int   // 消息体字节数
+	Err   error // 解码错误, 未注册的消息为util.ErrUnknownMsgID
 }
 
 // udp通知关闭,内部使用
@@ -46,6 +106,8 @@ func (self *SessionConnected) String() string    { return fmt.Sprintf("%+v", *se
 func (self *SessionConnectError) String() string { return fmt.Sprintf("%+v", *self) }
 func (self *SessionClosed) String() string       { return fmt.Sprintf("%+v", *self) }
 func (self *SessionCloseNotify) String() string  { return fmt.Sprintf("%+v", *self) }
+func (self *SessionCloseFrame) String() string   { return fmt.Sprintf("%+v", *self) }
+func (self *DecodeError) String() string         { return fmt.Sprintf("%+v", *self) }
 
 // 标记系统消息
 func (self *SessionInit) SystemMessage()         {}
@@ -54,6 +116,7 @@ func (self *SessionConnected) SystemMessage()    {}
 func (self *SessionConnectError) SystemMessage() {}
 func (self *SessionClosed) SystemMessage()       {}
 func (self *SessionCloseNotify) SystemMessage()  {}
+func (self *DecodeError) SystemMessage()         {}
 
 // 使用类型断言判断是否为系统消息
 type SystemMessageIdentifier interface {
@@ -1,7 +1,9 @@
 package tests
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"reflect"
 	"testing"
 
@@ -14,7 +16,10 @@ import (
 	_ "github.com/luis-quan/cellnet/proc/http"
 )
 
-const httpTestAddr = "127.0.0.1:8081"
+const (
+	httpTestAddr     = "127.0.0.1:8081"
+	httpMetaTestAddr = "127.0.0.1:8082"
+)
 
 func TestHttp(t *testing.T) {
 
@@ -63,6 +68,57 @@ func TestHttp(t *testing.T) {
 	p.Stop()
 }
 
+func TestHttpMessageMeta(t *testing.T) {
+
+	p := peer.NewGenericPeer("http.Acceptor", "httpserver", httpMetaTestAddr, nil)
+	p.(cellnet.HTTPAcceptor).SetMessageMetaServe("/meta")
+	proc.BindProcessorHandler(p, "http", func(raw cellnet.Event) {})
+	p.Start()
+	defer p.Stop()
+
+	var ack struct {
+		Count    int
+		Messages []*cellnet.MessageMetaInfo
+	}
+
+	resp, err := http.Get("http://" + httpMetaTestAddr + "/meta?rule=^tests\\.TestJSONEcho")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&ack)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := &cellnet.MessageMetaInfo{
+		ID:       cellnet.MessageMetaByFullName("tests.TestJSONEchoACK").ID,
+		FullName: "tests.TestJSONEchoACK",
+		Codec:    "json",
+		GoType:   "github.com/luis-quan/cellnet/tests.TestJSONEchoACK",
+		Fields: []*cellnet.MessageFieldInfo{
+			{Name: "Msg", Type: "string"},
+			{Name: "Value", Type: "int32"},
+		},
+	}
+
+	if ack.Count != 1 || len(ack.Messages) != 1 || !reflect.DeepEqual(ack.Messages[0], expect) {
+		t.Fatalf("unexpected meta %+v", ack)
+	}
+
+	// 正则错误
+	resp, err = http.Get("http://" + httpMetaTestAddr + "/meta?rule=(")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal("expect bad request, got", resp.StatusCode)
+	}
+}
+
 func requestThenValid(t *testing.T, method, path string, req, expectACK interface{}) {
 
 	p := peer.NewGenericPeer("http.Connector", "httpclient", httpTestAddr, nil).(cellnet.HTTPConnector)
@@ -7,6 +7,7 @@ import (
 	"github.com/luis-quan/cellnet"
 	"github.com/luis-quan/cellnet/codec"
 	_ "github.com/luis-quan/cellnet/codec/binary"
+	_ "github.com/luis-quan/cellnet/codec/json"
 	"github.com/luis-quan/cellnet/util"
 )
 
@@ -17,10 +18,23 @@ type TestEchoACK struct {
 
 func (self *TestEchoACK) String() string { return fmt.Sprintf("%+v", *self) }
 
+// 使用json编码的回显消息
+type TestJSONEchoACK struct {
+	Msg   string
+	Value int32
+}
+
+func (self *TestJSONEchoACK) String() string { return fmt.Sprintf("%+v", *self) }
+
 func init() {
 	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
 		Codec: codec.MustGetCodec("binary"),
 		Type:  reflect.TypeOf((*TestEchoACK)(nil)).Elem(),
 		ID:    int(util.StringHash("tests.TestEchoACK")),
 	})
+	cellnet.RegisterMessageMeta(&cellnet.MessageMeta{
+		Codec: codec.MustGetCodec("json"),
+		Type:  reflect.TypeOf((*TestJSONEchoACK)(nil)).Elem(),
+		ID:    int(util.StringHash("tests.TestJSONEchoACK")),
+	})
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
 	"github.com/luis-quan/cellnet/peer"
 	"github.com/luis-quan/cellnet/proc"
 	"github.com/luis-quan/cellnet/relay"
@@ -17,6 +18,12 @@ const (
 	relayBackendToAgent_Address = "127.0.0.1:16802"
 
 	AgentSessionIDMask = 10000
+
+	relayRouteGateway1_Address = "127.0.0.1:16803"
+	relayRouteGateway2_Address = "127.0.0.1:16804"
+	relayRouteBackendA_Address = "127.0.0.1:16805"
+	relayRouteBackendB_Address = "127.0.0.1:16806"
+	relayMultiPayload_Address  = "127.0.0.1:16807"
 )
 
 var (
@@ -167,3 +174,206 @@ func TestRelay(t *testing.T) {
 	relay_Client.Stop()
 
 }
+
+// 启动同步连接器, 返回连接的会话
+func relay_Connect(t *testing.T, name, address string, callback cellnet.EventCallback) (cellnet.GenericPeer, cellnet.Session) {
+
+	p := peer.NewGenericPeer("tcp.SyncConnector", name, address, nil)
+	proc.BindProcessorHandler(p, "tcp.ltv", callback)
+	p.Start()
+
+	ses := p.(cellnet.TCPConnector).Session()
+	if ses.ID() == 0 {
+		t.Fatalf("%s connect failed", name)
+	}
+
+	return p, ses
+}
+
+func relay_Listen(name, address string, callback cellnet.EventCallback) cellnet.GenericPeer {
+
+	p := peer.NewGenericPeer("tcp.Acceptor", name, address, nil)
+	proc.BindProcessorHandler(p, "tcp.ltv", callback)
+	p.Start()
+
+	return p
+}
+
+// client -> gateway1 -> gateway2 -> backendA/backendB, 回应沿原路返回
+func TestRelayRouter(t *testing.T) {
+
+	// 后端按路由键回应自己的名字
+	backendHandler := func(name string) cellnet.EventCallback {
+		return func(ev cellnet.Event) {
+			if relayEv, ok := ev.(*relay.RecvMsgEvent); ok {
+				msg := relayEv.Message().(*TestJSONEchoACK)
+				relayEv.Reply(&TestJSONEchoACK{Msg: name, Value: int32(relayEv.RouteKey()) + msg.Value})
+			}
+		}
+	}
+
+	backendA := relay_Listen("backendA", relayRouteBackendA_Address, backendHandler("A"))
+	defer backendA.Stop()
+
+	backendB := relay_Listen("backendB", relayRouteBackendB_Address, backendHandler("B"))
+	defer backendB.Stop()
+
+	ignore := func(ev cellnet.Event) {}
+
+	// gateway2: 1-50 -> A, 51-100 -> B
+	router2 := relay.NewRouter()
+	gateway2 := relay_Listen("gateway2", relayRouteGateway2_Address, ignore)
+	defer gateway2.Stop()
+	relay.BindRouter(gateway2, router2)
+
+	toA, sesA := relay_Connect(t, "gateway2->A", relayRouteBackendA_Address, ignore)
+	defer toA.Stop()
+	relay.BindRouter(toA, router2)
+
+	toB, sesB := relay_Connect(t, "gateway2->B", relayRouteBackendB_Address, ignore)
+	defer toB.Stop()
+	relay.BindRouter(toB, router2)
+
+	if router2.AddRoute(1, 50, sesA) != nil || router2.AddRoute(51, 100, sesB) != nil {
+		t.Fatal("add route failed")
+	}
+
+	if router2.AddRoute(40, 60, sesA) != relay.ErrRouteOverlap {
+		t.Fatal("expect route overlap")
+	}
+
+	// gateway1: 1-100 -> gateway2
+	router1 := relay.NewRouter()
+	gateway1 := relay_Listen("gateway1", relayRouteGateway1_Address, ignore)
+	defer gateway1.Stop()
+	relay.BindRouter(gateway1, router1)
+
+	to2, ses2 := relay_Connect(t, "gateway1->gateway2", relayRouteGateway2_Address, ignore)
+	defer to2.Stop()
+	relay.BindRouter(to2, router1)
+
+	router1.AddRoute(1, 100, ses2)
+
+	replies := make(chan *TestJSONEchoACK, 2)
+
+	client, clientSes := relay_Connect(t, "client", relayRouteGateway1_Address, func(ev cellnet.Event) {
+		if relayEv, ok := ev.(*relay.RecvMsgEvent); ok {
+			replies <- relayEv.Message().(*TestJSONEchoACK)
+		}
+	})
+	defer client.Stop()
+
+	for _, key := range []int64{10, 60} {
+
+		relay.RouteTo(clientSes, key, &TestJSONEchoACK{Msg: "route", Value: 1000})
+
+		select {
+		case ack := <-replies:
+			expect := "A"
+			if key > 50 {
+				expect = "B"
+			}
+
+			if ack.Msg != expect || ack.Value != int32(key)+1000 {
+				t.Fatalf("key %d: unexpected reply %+v", key, ack)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("key %d: reply timeout", key)
+		}
+	}
+
+	// 其他客户端伪造client的跳ID发送回应, 网关不转发
+	attacker, attackerSes := relay_Connect(t, "attacker", relayRouteGateway1_Address, ignore)
+	defer attacker.Stop()
+
+	data, meta, err := codec.EncodeMessage(&TestJSONEchoACK{Msg: "forged"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attackerSes.Send(&relay.RelayACK{
+		Msg:      data,
+		MsgID:    uint32(meta.ID),
+		TTL:      relay.DefaultRouteTTL,
+		Route:    []int64{1},
+		Backward: true,
+	})
+
+	select {
+	case ack := <-replies:
+		t.Fatalf("forged reply delivered %+v", ack)
+	case <-time.After(time.Millisecond * 200):
+	}
+
+	// 后端断开后, 路由被移除
+	toA.Stop()
+
+	for i := 0; i < 100 && router2.Lookup(10) != nil; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if router2.Lookup(10) != nil {
+		t.Fatal("route not removed after session closed")
+	}
+}
+
+func TestRelayMultiPayload(t *testing.T) {
+
+	server := relay_Listen("server", relayMultiPayload_Address, func(ev cellnet.Event) {
+
+		relayEv, ok := ev.(*relay.RecvMsgEvent)
+		if !ok {
+			return
+		}
+
+		msgs := relayEv.Messages()
+		if len(msgs) != 2 || msgs[1].(*TestJSONEchoACK).Msg != "second" {
+			t.Errorf("unexpected messages: %v", msgs)
+			return
+		}
+
+		var info TestJSONEchoACK
+		if !relayEv.PassThroughAsMsg(&info) || info.Msg != "passthrough" {
+			t.Errorf("unexpected passthrough msg: %v", relayEv.PassThroughMsgs())
+			return
+		}
+
+		relayEv.Reply(&TestJSONEchoACK{Msg: "reply", Value: int32(len(msgs))})
+	})
+	defer server.Stop()
+
+	replies := make(chan *relay.RecvMsgEvent, 1)
+
+	client, clientSes := relay_Connect(t, "client", relayMultiPayload_Address, func(ev cellnet.Event) {
+		if relayEv, ok := ev.(*relay.RecvMsgEvent); ok {
+			replies <- relayEv
+		}
+	})
+	defer client.Stop()
+
+	err := relay.Relay(clientSes,
+		&TestJSONEchoACK{Msg: "first"},
+		&TestJSONEchoACK{Msg: "second"},
+		int64(100),
+		map[string]string{"trace": "abc"},
+		relay.PassThroughMsg{Msg: &TestJSONEchoACK{Msg: "passthrough"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-replies:
+		ack := ev.Message().(*TestJSONEchoACK)
+		if ack.Msg != "reply" || ack.Value != 2 {
+			t.Fatalf("unexpected reply: %+v", ack)
+		}
+
+		// 透传值原样返回
+		if ev.PassThroughAsInt64() != 100 || ev.PassThroughAsMap()["trace"] != "abc" || len(ev.PassThroughMsgs()) != 1 {
+			t.Fatal("passthrough not returned")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("reply timeout")
+	}
+}
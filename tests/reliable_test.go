@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
+	"github.com/luis-quan/cellnet/peer"
+	"github.com/luis-quan/cellnet/proc"
+	"github.com/luis-quan/cellnet/reliable"
+)
+
+const (
+	reliable_Address      = "127.0.0.1:7951"
+	reliableLimit_Address = "127.0.0.1:7952"
+	reliableOff_Address   = "127.0.0.1:7953"
+	reliableFull_Address  = "127.0.0.1:7954"
+)
+
+func TestReliableRedeliver(t *testing.T) {
+
+	meta := cellnet.MessageMetaByType(reflect.TypeOf((*TestJSONEchoACK)(nil)).Elem())
+	reliable.SetReliable(meta, true)
+	defer reliable.SetReliable(meta, false)
+
+	acceptedList := make(chan cellnet.Session, 10)
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", reliable_Address, nil)
+	reliable.Enable(acceptor, reliable.Option{AckInterval: time.Millisecond * 300})
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionAccepted:
+			acceptedList <- ev.Session()
+		case *TestJSONEchoACK:
+			recv <- msg
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connectedList := make(chan cellnet.Session, 10)
+
+	connector := peer.NewGenericPeer("tcp.Connector", "client", reliable_Address, nil)
+	connector.(cellnet.TCPConnector).SetReconnectDuration(time.Millisecond * 50)
+	reliable.Enable(connector, reliable.Option{})
+	proc.BindProcessorHandler(connector, "tcp.ltv", func(ev cellnet.Event) {
+		if _, ok := ev.Message().(*cellnet.SessionConnected); ok {
+			connectedList <- ev.Session()
+		}
+	})
+	connector.Start()
+	defer connector.Stop()
+
+	var serverSes cellnet.Session
+
+	select {
+	case serverSes = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	select {
+	case <-connectedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("connect timeout")
+	}
+
+	clientSes := connector.(cellnet.TCPConnector).Session()
+
+	for _, msg := range []string{"1", "2", "3"} {
+		clientSes.Send(&TestJSONEchoACK{Msg: msg})
+	}
+
+	resume_Expect(t, recv, "1", "2", "3")
+
+	// 确认前断开, 重连后重发的消息被去重
+	if reliable.Unacked(clientSes) != 3 {
+		t.Fatalf("expect 3 unacked, got %d", reliable.Unacked(clientSes))
+	}
+
+	serverSes.Close()
+
+	select {
+	case <-connectedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("reconnect timeout")
+	}
+
+	clientSes.Send(&TestJSONEchoACK{Msg: "4"})
+
+	resume_Expect(t, recv, "4")
+
+	select {
+	case msg := <-recv:
+		t.Fatalf("duplicated message %s", msg.Msg)
+	case <-time.After(time.Millisecond * 500):
+	}
+
+	// 累计确认后不再保留
+	for i := 0; i < 100 && reliable.Unacked(clientSes) != 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if reliable.Unacked(clientSes) != 0 {
+		t.Fatalf("expect all acked, got %d", reliable.Unacked(clientSes))
+	}
+}
+
+// 启动接受端, 返回收到的消息, 连接端不启用可靠传输, 直接发送ReliableFrame
+func reliable_StartRawPair(t *testing.T, address string, enable bool) (acceptor, connector cellnet.GenericPeer, recv chan *TestJSONEchoACK) {
+
+	recv = make(chan *TestJSONEchoACK, 100)
+
+	acceptor = peer.NewGenericPeer("tcp.Acceptor", "server", address, nil)
+	if enable {
+		reliable.Enable(acceptor, reliable.Option{})
+	}
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		if msg, ok := ev.Message().(*TestJSONEchoACK); ok {
+			recv <- msg
+		}
+	})
+	acceptor.Start()
+
+	connector = peer.NewGenericPeer("tcp.SyncConnector", "client", address, nil)
+	proc.BindProcessorHandler(connector, "tcp.ltv", func(ev cellnet.Event) {})
+	connector.Start()
+
+	if connector.(cellnet.TCPConnector).Session().ID() == 0 {
+		t.Fatal("connect failed")
+	}
+
+	return
+}
+
+func reliable_Frame(t *testing.T, streamID int64, seq uint64, text string) *reliable.ReliableFrame {
+
+	data, meta, err := codec.EncodeMessage(&TestJSONEchoACK{Msg: text}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &reliable.ReliableFrame{StreamID: streamID, Seq: seq, MsgID: uint32(meta.ID), Data: data}
+}
+
+func reliable_Count(recv chan *TestJSONEchoACK) (count int) {
+
+	for {
+		select {
+		case <-recv:
+			count++
+		case <-time.After(time.Millisecond * 300):
+			return
+		}
+	}
+}
+
+func TestReliableLimit(t *testing.T) {
+
+	acceptor, connector, recv := reliable_StartRawPair(t, reliableLimit_Address, true)
+	defer acceptor.Stop()
+
+	ses := connector.(cellnet.TCPConnector).Session()
+
+	// 解码失败的消息不记录序号, 之后同序号的消息可以收到
+	bad := reliable_Frame(t, 1, 1, "bad")
+	bad.Data = []byte("{")
+	ses.Send(bad)
+	ses.Send(reliable_Frame(t, 1, 1, "good"))
+
+	resume_Expect(t, recv, "good")
+
+	// 每个会话的流数量有上限(16), 已经有一个流
+	for i := int64(2); i <= 20; i++ {
+		ses.Send(reliable_Frame(t, i, 1, "stream"))
+	}
+
+	if count := reliable_Count(recv); count != 15 {
+		t.Fatalf("expect 15 streams accepted, got %d", count)
+	}
+
+	if reliable.TrackedSessions(acceptor) != 1 {
+		t.Fatalf("expect 1 tracked session, got %d", reliable.TrackedSessions(acceptor))
+	}
+
+	// 会话关闭后接收状态不再引用会话
+	connector.Stop()
+
+	for i := 0; i < 100 && reliable.TrackedSessions(acceptor) != 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if reliable.TrackedSessions(acceptor) != 0 {
+		t.Fatal("closed session still tracked")
+	}
+}
+
+func TestReliableNotEnabled(t *testing.T) {
+
+	acceptor, connector, recv := reliable_StartRawPair(t, reliableOff_Address, false)
+	defer acceptor.Stop()
+
+	// 没有启用可靠传输的Peer丢弃可靠消息
+	connector.(cellnet.TCPConnector).Session().Send(reliable_Frame(t, 1, 1, "hello"))
+
+	if count := reliable_Count(recv); count != 0 {
+		t.Fatalf("expect frame dropped, got %d", count)
+	}
+}
+
+func TestReliableUnackedOverflow(t *testing.T) {
+
+	meta := cellnet.MessageMetaByType(reflect.TypeOf((*TestJSONEchoACK)(nil)).Elem())
+	reliable.SetReliable(meta, true)
+	defer reliable.SetReliable(meta, false)
+
+	recv := make(chan *TestJSONEchoACK, 10)
+	serverClosed := make(chan *cellnet.SessionClosed, 1)
+
+	// 接收方不回复确认
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", reliableFull_Address, nil)
+	reliable.Enable(acceptor, reliable.Option{AckInterval: time.Hour})
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionClosed:
+			serverClosed <- msg
+		case *TestJSONEchoACK:
+			recv <- msg
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connector := peer.NewGenericPeer("tcp.SyncConnector", "client", reliableFull_Address, nil)
+	reliable.Enable(connector, reliable.Option{MaxUnacked: 3})
+	proc.BindProcessorHandler(connector, "tcp.ltv", func(ev cellnet.Event) {})
+	connector.Start()
+	defer connector.Stop()
+
+	ses := connector.(cellnet.TCPConnector).Session()
+
+	for _, msg := range []string{"1", "2", "3", "4"} {
+		ses.Send(&TestJSONEchoACK{Msg: msg})
+	}
+
+	resume_Expect(t, recv, "1", "2", "3")
+
+	// 超过上限的消息不发送, 关闭会话, 不静默丢弃
+	select {
+	case msg := <-serverClosed:
+		if msg.Reason != cellnet.CloseReason_UnackedOverflow || msg.Initiator != cellnet.CloseInitiator_Remote {
+			t.Fatalf("unexpected close %+v", msg)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("session not closed")
+	}
+
+	select {
+	case msg := <-recv:
+		t.Fatalf("unexpected message %s", msg.Msg)
+	default:
+	}
+}
@@ -0,0 +1,309 @@
+package tests
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/peer"
+	"github.com/luis-quan/cellnet/proc"
+	"github.com/luis-quan/cellnet/resume"
+)
+
+const (
+	resume_Address         = "127.0.0.1:7901"
+	resumeIdentity_Address = "127.0.0.1:7902"
+	resumePending_Address  = "127.0.0.1:7903"
+)
+
+func resume_Expect(t *testing.T, recv chan *TestJSONEchoACK, msgList ...string) {
+
+	for _, expect := range msgList {
+		select {
+		case msg := <-recv:
+			if msg.Msg != expect {
+				t.Fatalf("expect %s, got %s", expect, msg.Msg)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expect %s, timeout", expect)
+		}
+	}
+}
+
+// 模拟网络断开, 直接关闭接受端的底层连接
+func resume_Drop(t *testing.T, ses cellnet.Session) {
+
+	conn, ok := ses.Raw().(net.Conn)
+	if !ok {
+		t.Fatal("session not connected")
+	}
+
+	conn.Close()
+
+	for i := 0; i < 100 && ses.Raw() != nil; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+
+	acceptedList := make(chan cellnet.Session, 10)
+	serverClosed := make(chan cellnet.Session, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", resume_Address, nil)
+	resume.SetOption(acceptor, resume.Option{GracePeriod: time.Millisecond * 500, AckInterval: 2})
+	proc.BindProcessorHandler(acceptor, "tcp.ltv.resume", func(ev cellnet.Event) {
+
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionAccepted:
+			acceptedList <- ev.Session()
+		case *cellnet.SessionClosed:
+			serverClosed <- ev.Session()
+		case *TestJSONEchoACK:
+			ev.Session().Send(msg)
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connectedList := make(chan cellnet.Session, 10)
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	connector := peer.NewGenericPeer("tcp.Connector", "client", resume_Address, nil)
+	connector.(cellnet.TCPConnector).SetReconnectDuration(time.Millisecond * 50)
+	proc.BindProcessorHandler(connector, "tcp.ltv.resume", func(ev cellnet.Event) {
+
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionConnected:
+			connectedList <- ev.Session()
+		case *TestJSONEchoACK:
+			recv <- msg
+		}
+	})
+	connector.Start()
+
+	var serverSes, clientSes cellnet.Session
+
+	select {
+	case serverSes = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	select {
+	case clientSes = <-connectedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("connect timeout")
+	}
+
+	if serverSes.ID() != clientSes.ID() {
+		t.Fatalf("logical session id mismatch %d %d", serverSes.ID(), clientSes.ID())
+	}
+
+	clientSes.Send(&TestJSONEchoACK{Msg: "1"})
+	resume_Expect(t, recv, "1")
+
+	// 断开后客户端发送的消息在恢复后送达
+	resume_Drop(t, serverSes)
+
+	for _, msg := range []string{"2", "3", "4"} {
+		clientSes.Send(&TestJSONEchoACK{Msg: msg})
+	}
+
+	resume_Expect(t, recv, "2", "3", "4")
+
+	// 断开期间服务器发送的消息在恢复后送达
+	resume_Drop(t, serverSes)
+
+	for _, msg := range []string{"5", "6"} {
+		serverSes.Send(&TestJSONEchoACK{Msg: msg})
+	}
+
+	resume_Expect(t, recv, "5", "6")
+
+	select {
+	case ses := <-acceptedList:
+		t.Fatalf("unexpected accepted %d", ses.ID())
+	case ses := <-connectedList:
+		t.Fatalf("unexpected connected %d", ses.ID())
+	case ses := <-serverClosed:
+		t.Fatalf("unexpected closed %d", ses.ID())
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	if resume.ManagerOf(acceptor).GetSession(serverSes.ID()) != serverSes {
+		t.Fatal("logical session not found")
+	}
+
+	// 客户端停止后, 宽限期结束时服务器通知关闭
+	connector.Stop()
+
+	select {
+	case ses := <-serverClosed:
+		if ses != serverSes {
+			t.Fatalf("unexpected closed session %d", ses.ID())
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("grace period not expired")
+	}
+
+	if resume.ManagerOf(acceptor).SessionCount() != 0 {
+		t.Fatal("logical session not removed")
+	}
+}
+
+func TestResumeIdentity(t *testing.T) {
+
+	acceptedList := make(chan cellnet.Session, 10)
+	serverClosed := make(chan cellnet.Session, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", resumeIdentity_Address, nil)
+	resume.SetOption(acceptor, resume.Option{GracePeriod: time.Millisecond * 300})
+	proc.BindProcessorHandler(acceptor, "tcp.ltv.resume", func(ev cellnet.Event) {
+
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionAccepted:
+			acceptedList <- ev.Session()
+		case *cellnet.SessionClosed:
+			serverClosed <- ev.Session()
+		case *TestJSONEchoACK:
+			ev.Session().Send(msg)
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connectedList := make(chan cellnet.Session, 10)
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	connector := peer.NewGenericPeer("tcp.Connector", "client", resumeIdentity_Address, nil)
+	connector.(cellnet.TCPConnector).SetReconnectDuration(time.Millisecond * 50)
+	proc.BindProcessorHandler(connector, "tcp.ltv.resume", func(ev cellnet.Event) {
+
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionConnected:
+			connectedList <- ev.Session()
+		case *TestJSONEchoACK:
+			recv <- msg
+		}
+	})
+	connector.Start()
+
+	var serverSes, clientSes cellnet.Session
+
+	select {
+	case serverSes = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	select {
+	case clientSes = <-connectedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("connect timeout")
+	}
+
+	// 逻辑会话使用resume.Manager的用户键索引
+	if _, err := peer.Bind(serverSes, "user", peer.Duplicate_RejectNew); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := resume.ManagerOf(acceptor)
+
+	if peer.SessionByIdentity(mgr, "user") != serverSes {
+		t.Fatal("logical session not bound")
+	}
+
+	// 恢复后绑定保留
+	resume_Drop(t, serverSes)
+
+	clientSes.Send(&TestJSONEchoACK{Msg: "1"})
+	resume_Expect(t, recv, "1")
+
+	if peer.SessionByIdentity(mgr, "user") != serverSes {
+		t.Fatal("binding lost after resume")
+	}
+
+	if _, err := peer.Bind(serverSes, "user", peer.Duplicate_RejectNew); err != nil {
+		t.Fatal("rebind after resume:", err)
+	}
+
+	// 逻辑会话结束后解除绑定
+	connector.Stop()
+
+	select {
+	case <-serverClosed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("grace period not expired")
+	}
+
+	if peer.SessionByIdentity(mgr, "user") != nil {
+		t.Fatal("binding not removed")
+	}
+
+	if key, ok := peer.IdentityOf(serverSes); !ok || key != "user" {
+		t.Fatal("identity should remain after close")
+	}
+}
+
+func TestResumePendingOverflow(t *testing.T) {
+
+	acceptedList := make(chan cellnet.Session, 10)
+	serverClosed := make(chan *cellnet.SessionClosed, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", resumePending_Address, nil)
+	resume.SetOption(acceptor, resume.Option{GracePeriod: time.Second * 10, MaxReplay: 4})
+	proc.BindProcessorHandler(acceptor, "tcp.ltv.resume", func(ev cellnet.Event) {
+
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionAccepted:
+			acceptedList <- ev.Session()
+		case *cellnet.SessionClosed:
+			serverClosed <- msg
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	// 不重连
+	connector := peer.NewGenericPeer("tcp.Connector", "client", resumePending_Address, nil)
+	proc.BindProcessorHandler(connector, "tcp.ltv.resume", func(ev cellnet.Event) {})
+	connector.Start()
+	defer connector.Stop()
+
+	var serverSes cellnet.Session
+
+	select {
+	case serverSes = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	resume_Drop(t, serverSes)
+
+	// 缓存未超过MaxReplay时等待恢复
+	for i := 0; i < 4; i++ {
+		serverSes.Send(&TestJSONEchoACK{Msg: "pending"})
+	}
+
+	if serverSes.(*resume.Session).IsClosed() {
+		t.Fatal("logical session closed before overflow")
+	}
+
+	// 超过时不再等待宽限期, 立即结束逻辑会话
+	serverSes.Send(&TestJSONEchoACK{Msg: "overflow"})
+
+	select {
+	case msg := <-serverClosed:
+		if msg.Reason != cellnet.CloseReason_UnackedOverflow || msg.Initiator != cellnet.CloseInitiator_Local {
+			t.Fatalf("unexpected closed %s %s", msg.Reason, msg.Initiator)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("logical session not abandoned")
+	}
+
+	if resume.ManagerOf(acceptor).SessionCount() != 0 {
+		t.Fatal("logical session not removed")
+	}
+}
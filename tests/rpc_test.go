@@ -1,17 +1,37 @@
 package tests
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/codec"
 	"github.com/luis-quan/cellnet/peer"
 	"github.com/luis-quan/cellnet/proc"
+	"github.com/luis-quan/cellnet/proc/gorillaws"
 	"github.com/luis-quan/cellnet/proc/tcp"
 	"github.com/luis-quan/cellnet/rpc"
+	"github.com/luis-quan/cellnet/timer"
 )
 
-const syncRPC_Address = "127.0.0.1:9201"
+const (
+	syncRPC_Address      = "127.0.0.1:9201"
+	fakeClockRPC_Address = "127.0.0.1:9202"
+	cancelRPC_Address    = "127.0.0.1:9203"
+	pendingRPC_Address   = "127.0.0.1:9204"
+	errorRPC_Address     = "127.0.0.1:9205"
+	streamRPC_Address    = "127.0.0.1:9206"
+	typeRPC_Address      = "127.0.0.1:9207"
+	interceptRPC_Address = "127.0.0.1:9208"
+	metaRPC_Address      = "127.0.0.1:9209"
+	limitRPC_Address     = "127.0.0.1:9210"
+	plainRPC_Address     = "127.0.0.1:9211"
+	wsTypeRPC_Address    = "127.0.0.1:9212"
+)
 
 var (
 	syncRPC_Signal  *SignalTester
@@ -111,7 +131,6 @@ func typeRPC_OnClientEvent(ev cellnet.Event) {
 
 			copy := i + 1
 
-			// 注意, 这里不能使用CallType, 异步第一次回来后, 就将rpc上下文清楚,导致第二次之后的回调无法触发, 不属于bug
 			rpc.CallSyncType(ev.Session(), &TestEchoACK{
 				Msg:   "type",
 				Value: 1234,
@@ -179,12 +198,662 @@ func TestTypeRPC(t *testing.T) {
 	rpc_Acceptor.Stop()
 }
 
+func TestRPCTimeoutWithFakeClock(t *testing.T) {
+
+	clock := timer.NewFakeClock(time.Now())
+	timer.SetClock(clock)
+	defer timer.SetClock(nil)
+
+	// 服务器不回应任何请求
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", fakeClockRPC_Address, nil)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connector := peer.NewGenericPeer("tcp.SyncConnector", "client", fakeClockRPC_Address, nil)
+	proc.BindProcessorHandler(connector, "tcp.ltv", func(ev cellnet.Event) {})
+	connector.Start()
+	defer connector.Stop()
+
+	result := make(chan error)
+
+	go func() {
+		_, err := rpc.CallSync(connector, &TestJSONEchoACK{
+			Msg:   "timeout",
+			Value: 1234,
+		}, time.Second*5)
+
+		result <- err
+	}()
+
+	// 等待请求的超时定时器创建后, 推进时间
+	clock.BlockUntil(1)
+	clock.Advance(time.Second * 5)
+
+	if err := <-result; err != rpc.ErrTimeout {
+		t.Fatalf("expect timeout, got %v", err)
+	}
+}
+
+// 启动服务器, 收到请求后调用onRequest, 返回客户端连接
+func rpc_StartSilentPair(t *testing.T, address string, onRequest func(ev cellnet.Event)) (acceptor, connector cellnet.GenericPeer) {
+
+	acceptor = peer.NewGenericPeer("tcp.Acceptor", "server", address, nil)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		if _, ok := ev.Message().(*TestJSONEchoACK); ok && onRequest != nil {
+			onRequest(ev)
+		}
+	})
+	acceptor.Start()
+
+	connector = peer.NewGenericPeer("tcp.SyncConnector", "client", address, nil)
+	proc.BindProcessorHandler(connector, "tcp.ltv", func(ev cellnet.Event) {})
+	connector.Start()
+
+	if connector.(cellnet.TCPConnector).Session().ID() == 0 {
+		t.Fatal("connect failed")
+	}
+
+	return
+}
+
+func TestRPCContextCancel(t *testing.T) {
+
+	acceptor, connector := rpc_StartSilentPair(t, cancelRPC_Address, func(ev cellnet.Event) {
+
+		// 断开连接, 不回应
+		if ev.Message().(*TestJSONEchoACK).Msg == "close" {
+			ev.Session().Close()
+		}
+	})
+
+	defer acceptor.Stop()
+
+	// 取消
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err := rpc.CallSyncContext(ctx, connector, &TestJSONEchoACK{Msg: "cancel"})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+
+	// 异步请求, 取消后回调
+	ctx, cancel = context.WithCancel(context.Background())
+
+	result := make(chan interface{})
+	rpc.CallContext(ctx, connector, &TestJSONEchoACK{Msg: "cancel"}, func(raw interface{}) {
+		result <- raw
+	})
+
+	cancel()
+
+	if raw := <-result; raw != context.Canceled {
+		t.Fatalf("expect canceled, got %v", raw)
+	}
+
+	// 不大于0的超时立即返回, 不限时的请求使用ctx
+	if _, err = rpc.CallSync(connector, &TestJSONEchoACK{Msg: "cancel"}, 0); err != rpc.ErrTimeout {
+		t.Fatalf("expect timeout, got %v", err)
+	}
+
+	// 没有队列时在当前goroutine回调
+	var timeoutRaw interface{}
+	rpc.Call(connector, &TestJSONEchoACK{Msg: "cancel"}, 0, func(raw interface{}) {
+		timeoutRaw = raw
+	})
+
+	if timeoutRaw != rpc.ErrTimeout {
+		t.Fatalf("expect timeout, got %v", timeoutRaw)
+	}
+
+	// 会话断开时, 请求立即失败, 不等待超时
+	begin := time.Now()
+	_, err = rpc.CallSync(connector, &TestJSONEchoACK{Msg: "close"}, time.Second*10)
+	if err != rpc.ErrSessionClosed || time.Since(begin) > time.Second*5 {
+		t.Fatalf("expect session closed, got %v", err)
+	}
+}
+
+func TestRPCPendingCalls(t *testing.T) {
+
+	acceptor, connector := rpc_StartSilentPair(t, pendingRPC_Address, nil)
+
+	defer acceptor.Stop()
+
+	ses := connector.(cellnet.TCPConnector).Session()
+
+	result := make(chan interface{}, 3)
+
+	for i := 0; i < 3; i++ {
+		rpc.Call(ses, &TestJSONEchoACK{Msg: "pending", Value: int32(i)}, time.Minute, func(raw interface{}) {
+			result <- raw
+		})
+	}
+
+	pending := rpc.PendingCalls(ses)
+	if len(pending) != 3 {
+		t.Fatalf("expect 3 pending calls, got %d", len(pending))
+	}
+
+	if pending[0].Request.(*TestJSONEchoACK).Value != 0 || pending[0].Deadline.IsZero() {
+		t.Fatalf("unexpected pending call: %+v", pending[0])
+	}
+
+	// 取消单个请求
+	if !rpc.CancelCall(ses, pending[0].CallID) || rpc.CancelCall(ses, pending[0].CallID) {
+		t.Fatal("cancel call failed")
+	}
+
+	if raw := <-result; raw != rpc.ErrCanceled {
+		t.Fatalf("expect canceled, got %v", raw)
+	}
+
+	// 取消剩余请求
+	if n := rpc.CancelAllCalls(ses); n != 2 {
+		t.Fatalf("expect 2 canceled calls, got %d", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if raw := <-result; raw != rpc.ErrCanceled {
+			t.Fatalf("expect canceled, got %v", raw)
+		}
+	}
+
+	if len(rpc.PendingCalls(ses)) != 0 {
+		t.Fatal("pending calls not cleared")
+	}
+}
+
+func TestRPCReplyError(t *testing.T) {
+
+	acceptor, connector := rpc_StartSilentPair(t, errorRPC_Address, func(ev cellnet.Event) {
+
+		msg := ev.Message().(*TestJSONEchoACK)
+		if msg.Value == 0 {
+			ev.(*rpc.RecvMsgEvent).ReplyError(rpc.StatusCode_NotFound, "item not found")
+		} else {
+			ev.(*rpc.RecvMsgEvent).Reply(msg)
+		}
+	})
+
+	defer acceptor.Stop()
+
+	_, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "error"}, time.Second*5)
+
+	callErr, ok := err.(*rpc.CallError)
+	if !ok || callErr.Code != rpc.StatusCode_NotFound || callErr.Msg != "item not found" {
+		t.Fatalf("expect call error, got %v", err)
+	}
+
+	if rpc.CodeOf(err) != rpc.StatusCode_NotFound {
+		t.Fatalf("unexpected code: %s", rpc.CodeOf(err))
+	}
+
+	// 异步请求
+	result := make(chan interface{})
+	rpc.Call(connector, &TestJSONEchoACK{Msg: "error"}, time.Second*5, func(raw interface{}) {
+		result <- raw
+	})
+
+	if err, ok := (<-result).(error); !ok || rpc.CodeOf(err) != rpc.StatusCode_NotFound {
+		t.Fatalf("expect call error, got %v", err)
+	}
+
+	// 正常回应不受影响
+	ack, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "ok", Value: 1}, time.Second*5)
+	if err != nil || ack.(*TestJSONEchoACK).Value != 1 {
+		t.Fatalf("expect reply, got %v %v", ack, err)
+	}
+}
+
+func TestRPCStream(t *testing.T) {
+
+	serverCanceled := make(chan error, 1)
+
+	acceptor, connector := rpc_StartSilentPair(t, streamRPC_Address, func(ev cellnet.Event) {
+
+		streamEv, ok := ev.(*rpc.RecvStreamEvent)
+		if !ok {
+			return
+		}
+
+		openMsg := streamEv.Msg.(*TestJSONEchoACK)
+		stream := streamEv.Stream
+
+		go func() {
+			switch openMsg.Msg {
+			case "list": // 服务器流, 发送Value个消息
+				for i := int32(0); i < openMsg.Value; i++ {
+					if err := stream.Send(&TestJSONEchoACK{Msg: "item", Value: i}); err != nil {
+						t.Errorf("server send: %v", err)
+						return
+					}
+				}
+
+				stream.Close()
+			case "echo": // 双向流, 回显直到客户端结束发送
+				for {
+					msg, err := stream.Recv()
+					if err != nil {
+						if err != io.EOF {
+							t.Errorf("server recv: %v", err)
+						}
+						break
+					}
+
+					stream.Send(msg)
+				}
+
+				stream.CloseWithError(rpc.StatusCode_Internal, "echo done")
+			case "wait":
+				_, err := stream.Recv()
+				serverCanceled <- err
+			}
+		}()
+	})
+
+	defer acceptor.Stop()
+
+	// 服务器流, 超过接收窗口, 需要归还额度
+	stream, err := rpc.OpenStream(connector, &TestJSONEchoACK{Msg: "list", Value: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.Close()
+
+	for i := int32(0); ; i++ {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			if i != 100 {
+				t.Fatalf("expect 100 items, got %d", i)
+			}
+			break
+		}
+
+		if err != nil || msg.(*TestJSONEchoACK).Value != i {
+			t.Fatalf("unexpected item %d: %v %v", i, msg, err)
+		}
+	}
+
+	// 双向流
+	stream, err = rpc.OpenStream(connector, &TestJSONEchoACK{Msg: "echo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int32(0); i < 3; i++ {
+		if err := stream.Send(&TestJSONEchoACK{Msg: "hello", Value: i}); err != nil {
+			t.Fatal(err)
+		}
+
+		msg, err := stream.Recv()
+		if err != nil || msg.(*TestJSONEchoACK).Value != i {
+			t.Fatalf("unexpected echo %d: %v %v", i, msg, err)
+		}
+	}
+
+	stream.Close()
+
+	if stream.Send(&TestJSONEchoACK{}) != rpc.ErrStreamClosed {
+		t.Fatal("expect send on closed stream fail")
+	}
+
+	if _, err := stream.Recv(); rpc.CodeOf(err) != rpc.StatusCode_Internal {
+		t.Fatalf("expect internal error, got %v", err)
+	}
+
+	// 取消
+	stream, err = rpc.OpenStream(connector, &TestJSONEchoACK{Msg: "wait"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.Cancel()
+
+	if _, err := stream.Recv(); err != rpc.ErrCanceled {
+		t.Fatalf("expect canceled, got %v", err)
+	}
+
+	if err := <-serverCanceled; err != rpc.ErrCanceled {
+		t.Fatalf("expect server canceled, got %v", err)
+	}
+}
+
+func TestRPCStreamLimit(t *testing.T) {
+
+	// 服务器不读取流
+	acceptor, connector := rpc_StartSilentPair(t, limitRPC_Address, nil)
+
+	defer acceptor.Stop()
+
+	ses := connector.(cellnet.TCPConnector).Session()
+
+	// 不遵守额度, 直接发送超过接收窗口(32)的消息
+	stream, err := rpc.OpenStream(connector, &TestJSONEchoACK{Msg: "flood"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, meta, err := codec.EncodeMessage(&TestJSONEchoACK{Msg: "item"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 40; i++ {
+		ses.Send(&rpc.RemoteStreamFrame{
+			StreamID:  stream.ID(),
+			Initiator: true,
+			Kind:      int32(rpc.StreamFrame_Data),
+			MsgID:     uint32(meta.ID),
+			Data:      data,
+		})
+	}
+
+	if _, err := stream.Recv(); rpc.CodeOf(err) != rpc.StatusCode_ResourceExhausted {
+		t.Fatalf("expect window exceeded, got %v", err)
+	}
+
+	// 超过每个会话同时打开的流的上限(64)
+	for i := 0; i < 64; i++ {
+		if _, err := rpc.OpenStream(connector, &TestJSONEchoACK{Msg: "hold"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream, err = rpc.OpenStream(connector, &TestJSONEchoACK{Msg: "hold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Recv(); rpc.CodeOf(err) != rpc.StatusCode_ResourceExhausted {
+		t.Fatalf("expect too many streams, got %v", err)
+	}
+}
+
+func TestTypeRPCConcurrent(t *testing.T) {
+
+	acceptor, connector := rpc_StartSilentPair(t, typeRPC_Address, func(ev cellnet.Event) {
+
+		msg := ev.Message().(*TestJSONEchoACK)
+		switch msg.Msg {
+		case "echo":
+			ev.(*rpc.RecvMsgEvent).Reply(msg)
+		case "close":
+			ev.Session().Close()
+		}
+	})
+
+	defer acceptor.Stop()
+
+	// 相同回应类型的并发请求, 各自收到自己的回应
+	type result struct {
+		value int32
+		err   error
+	}
+
+	const total = 20
+
+	results := make(chan result, total)
+
+	for i := int32(0); i < total; i++ {
+		value := i
+		rpc.CallType(connector, &TestJSONEchoACK{Msg: "echo", Value: value}, time.Second*5, func(ack *TestJSONEchoACK, err error) {
+			if err == nil && ack.Value != value {
+				err = fmt.Errorf("expect %d, got %d", value, ack.Value)
+			}
+
+			results <- result{value, err}
+		})
+	}
+
+	for i := 0; i < total; i++ {
+		if r := <-results; r.err != nil {
+			t.Fatal(r.err)
+		}
+	}
+
+	// 异步超时
+	rpc.CallType(connector, &TestJSONEchoACK{Msg: "silent"}, time.Millisecond*50, func(ack *TestJSONEchoACK, err error) {
+		results <- result{err: err}
+	})
+
+	if r := <-results; r.err != rpc.ErrTimeout {
+		t.Fatalf("expect timeout, got %v", r.err)
+	}
+
+	// 会话断开
+	rpc.CallSyncType(connector, &TestJSONEchoACK{Msg: "close"}, time.Second*10, func(ack *TestJSONEchoACK, err error) {
+		if err != rpc.ErrSessionClosed {
+			t.Fatalf("expect session closed, got %v", err)
+		}
+	})
+}
+
+func TestTypeRPCPlainReply(t *testing.T) {
+
+	// 服务器直接发送回应消息
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", plainRPC_Address, nil)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		if msg, ok := ev.Message().(*TestJSONEchoACK); ok {
+			ev.Session().Send(msg)
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connector := peer.NewGenericPeer("tcp.SyncConnector", "client", plainRPC_Address, nil)
+	proc.BindProcessorHandler(connector, "tcp.ltv.type", func(ev cellnet.Event) {})
+	connector.Start()
+	defer connector.Stop()
+
+	for i := int32(1); i <= 3; i++ {
+		rpc.CallSyncType(connector, &TestJSONEchoACK{Msg: "plain", Value: i}, time.Second*5, func(ack *TestJSONEchoACK, err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ack.Value != i {
+				t.Fatalf("expect %d, got %d", i, ack.Value)
+			}
+		})
+	}
+
+	if calls := rpc.PendingCalls(connector.(cellnet.TCPConnector).Session()); len(calls) != 0 {
+		t.Fatalf("expect no pending call, got %d", len(calls))
+	}
+}
+
+func TestTypeRPCWebSocket(t *testing.T) {
+
+	// ws处理器不处理RPC, 由TypeRPCHooker处理
+	acceptor := peer.NewGenericPeer("gorillaws.Acceptor", "server", wsTypeRPC_Address, nil)
+	proc.BindProcessorHandler(acceptor, "gorillaws.ltv.type", func(ev cellnet.Event) {
+		if msg, ok := ev.Message().(*TestJSONEchoACK); ok {
+			ev.(*rpc.RecvMsgEvent).Reply(msg)
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connector := peer.NewGenericPeer("gorillaws.SyncConnector", "client", wsTypeRPC_Address, nil)
+	proc.BindProcessorHandler(connector, "gorillaws.ltv.type", func(ev cellnet.Event) {})
+	connector.Start()
+	defer connector.Stop()
+
+	rpc.CallSyncType(connector, &TestJSONEchoACK{Msg: "ws", Value: 7}, time.Second*5, func(ack *TestJSONEchoACK, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ack.Value != 7 {
+			t.Fatalf("expect 7, got %d", ack.Value)
+		}
+	})
+}
+
+func TestRPCInterceptor(t *testing.T) {
+
+	defer rpc.ClearInterceptors()
+
+	var serverCount, clientCount int32
+
+	rpc.AddServerInterceptor(rpc.LogServerInterceptor)
+
+	// 计数
+	rpc.AddServerInterceptor(func(ev *rpc.RecvMsgEvent, invoker rpc.ServerInvoker) {
+		atomic.AddInt32(&serverCount, 1)
+		invoker(ev)
+	})
+
+	// 权限检查及崩溃恢复
+	rpc.AddServerInterceptor(func(ev *rpc.RecvMsgEvent, invoker rpc.ServerInvoker) {
+
+		if ev.Msg.(*TestJSONEchoACK).Msg == "deny" {
+			ev.ReplyError(rpc.StatusCode_PermissionDenied, "denied")
+			return
+		}
+
+		defer func() {
+			if err := recover(); err != nil {
+				ev.ReplyError(rpc.StatusCode_Internal, fmt.Sprint(err))
+			}
+		}()
+
+		invoker(ev)
+	})
+
+	rpc.AddClientInterceptor(rpc.LogClientInterceptor)
+
+	rpc.AddClientInterceptor(func(info *rpc.CallInfo, reqMsg interface{}, onReply func(interface{}), invoker rpc.ClientInvoker) {
+
+		atomic.AddInt32(&clientCount, 1)
+
+		// 不发送, 直接返回
+		if reqMsg.(*TestJSONEchoACK).Msg == "local" {
+			onReply(&TestJSONEchoACK{Msg: "local", Value: 1})
+			return
+		}
+
+		invoker(info, reqMsg, onReply)
+	})
+
+	acceptor, connector := rpc_StartSilentPair(t, interceptRPC_Address, func(ev cellnet.Event) {
+
+		msg := ev.Message().(*TestJSONEchoACK)
+		if msg.Msg == "panic" {
+			panic("handler panic")
+		}
+
+		ev.(*rpc.RecvMsgEvent).Reply(msg)
+	})
+
+	defer acceptor.Stop()
+
+	if _, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "hello"}, time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "deny"}, time.Second*5); rpc.CodeOf(err) != rpc.StatusCode_PermissionDenied {
+		t.Fatalf("expect permission denied, got %v", err)
+	}
+
+	if _, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "panic"}, time.Second*5); rpc.CodeOf(err) != rpc.StatusCode_Internal {
+		t.Fatalf("expect internal error, got %v", err)
+	}
+
+	ack, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "local"}, time.Second*5)
+	if err != nil || ack.(*TestJSONEchoACK).Value != 1 {
+		t.Fatalf("expect local reply, got %v %v", ack, err)
+	}
+
+	if atomic.LoadInt32(&serverCount) != 3 || atomic.LoadInt32(&clientCount) != 4 {
+		t.Fatalf("unexpected interceptor count: server %d client %d", serverCount, clientCount)
+	}
+}
+
+func TestRPCMetadata(t *testing.T) {
+
+	var lateHandled int32
+
+	// 使用队列, 处理阻塞时仍然能及时收到请求
+	queue := cellnet.NewEventQueue()
+	queue.StartLoop()
+	defer queue.StopLoop()
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", metaRPC_Address, queue)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+
+		rpcEv, ok := ev.(*rpc.RecvMsgEvent)
+		if !ok {
+			return
+		}
+
+		switch rpcEv.Msg.(*TestJSONEchoACK).Msg {
+		case "meta":
+			if rpcEv.Deadline().IsZero() {
+				rpcEv.ReplyError(rpc.StatusCode_InvalidArgument, "deadline not propagated")
+				return
+			}
+
+			rpcEv.ReplyMeta = rpc.Metadata{"trace": rpcEv.Meta.Get("trace")}
+		case "slow":
+			// 阻塞后续请求的处理
+			time.Sleep(time.Millisecond * 200)
+		case "late":
+			atomic.StoreInt32(&lateHandled, 1)
+		}
+
+		rpcEv.Reply(rpcEv.Msg)
+	})
+	acceptor.Start()
+
+	defer acceptor.Stop()
+
+	connector := peer.NewGenericPeer("tcp.SyncConnector", "client", metaRPC_Address, nil)
+	proc.BindProcessorHandler(connector, "tcp.ltv", func(ev cellnet.Event) {})
+	connector.Start()
+
+	// 请求及回应的元数据
+	var replyMeta rpc.Metadata
+	_, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "meta"}, time.Second*5,
+		rpc.WithMeta("trace", "abc"),
+		rpc.WithReplyMeta(&replyMeta))
+
+	if err != nil || replyMeta.Get("trace") != "abc" {
+		t.Fatalf("unexpected reply meta: %v %v", replyMeta, err)
+	}
+
+	// 服务器处理前已经超过期限, 不再调用处理函数
+	rpc.Call(connector, &TestJSONEchoACK{Msg: "slow"}, time.Second*5, func(interface{}) {})
+
+	if _, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "late"}, time.Millisecond*50); err != rpc.ErrTimeout {
+		t.Fatalf("expect timeout, got %v", err)
+	}
+
+	if _, err := rpc.CallSync(connector, &TestJSONEchoACK{Msg: "after"}, time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&lateHandled) != 0 {
+		t.Fatal("expired request should not be handled")
+	}
+}
+
 func init() {
 	// 对TypeRPC增强
 	proc.RegisterProcessor("tcp.ltv.type", func(bundle proc.ProcessorBundle, userCallback cellnet.EventCallback, args ...interface{}) {
 
 		bundle.SetTransmitter(new(tcp.TCPMessageTransmitter))
 		bundle.SetHooker(proc.NewMultiHooker(new(tcp.MsgHooker), new(rpc.TypeRPCHooker)))
+		bundle.SetCallback(proc.NewQueuedEventCallback(rpc.NewInterceptedEventCallback(userCallback)))
+	})
+
+	proc.RegisterProcessor("gorillaws.ltv.type", func(bundle proc.ProcessorBundle, userCallback cellnet.EventCallback, args ...interface{}) {
+
+		bundle.SetTransmitter(new(gorillaws.WSMessageTransmitter))
+		bundle.SetHooker(proc.NewMultiHooker(new(gorillaws.MsgHooker), new(rpc.TypeRPCHooker)))
 		bundle.SetCallback(proc.NewQueuedEventCallback(userCallback))
 	})
 
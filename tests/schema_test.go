@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/peer"
+	"github.com/luis-quan/cellnet/proc"
+	"github.com/luis-quan/cellnet/schema"
+)
+
+const (
+	schema_Address = "127.0.0.1:7961"
+)
+
+func TestSchemaHandshake(t *testing.T) {
+
+	hash, count, err := schema.MetaHash("^tests\\.")
+	if err != nil || count == 0 {
+		t.Fatal("meta hash failed", count, err)
+	}
+
+	if hash2, _, _ := schema.MetaHash("^tests\\."); hash2 != hash {
+		t.Fatal("meta hash not stable")
+	}
+
+	acceptedList := make(chan cellnet.Session, 10)
+	closedList := make(chan *cellnet.SessionClosed, 10)
+	remoteList := make(chan *schema.SchemaHandshake, 10)
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", schema_Address, nil)
+	schema.Enable(acceptor, schema.Option{
+		Version: 2,
+		Policy: func(ses cellnet.Session, local, remote *schema.SchemaHandshake) error {
+			remoteList <- remote
+			return schema.DefaultPolicy(ses, local, remote)
+		},
+	})
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionAccepted:
+			acceptedList <- ev.Session()
+		case *cellnet.SessionClosed:
+			closedList <- msg
+		case *TestJSONEchoACK:
+			recv <- msg
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	schema_Connect := func(version uint32) (cellnet.GenericPeer, chan cellnet.Session) {
+
+		connectedList := make(chan cellnet.Session, 1)
+
+		p := peer.NewGenericPeer("tcp.Connector", "client", schema_Address, nil)
+		schema.Enable(p, schema.Option{Version: version})
+		proc.BindProcessorHandler(p, "tcp.ltv", func(ev cellnet.Event) {
+			if _, ok := ev.Message().(*cellnet.SessionConnected); ok {
+				connectedList <- ev.Session()
+			}
+		})
+		p.Start()
+
+		return p, connectedList
+	}
+
+	// 版本相同, 握手完成后双方才收到连接事件
+	client, connectedList := schema_Connect(2)
+
+	var clientSes cellnet.Session
+	select {
+	case clientSes = <-connectedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("connect timeout")
+	}
+
+	select {
+	case ses := <-acceptedList:
+		if remote, ok := schema.RemoteOf(ses); !ok || remote.Version != 2 || remote.MetaHash != schema.Local(acceptor).MetaHash {
+			t.Fatalf("unexpected remote %+v", remote)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	<-remoteList
+
+	clientSes.Send(&TestJSONEchoACK{Msg: "hello"})
+
+	select {
+	case msg := <-recv:
+		if msg.Msg != "hello" {
+			t.Fatal("unexpected msg", msg.Msg)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("recv timeout")
+	}
+
+	client.Stop()
+	session_ExpectClosed(t, closedList, cellnet.CloseReason_PeerStopping, cellnet.CloseInitiator_Remote, 0)
+
+	// 版本不同, 拒绝后断开, 用户收不到连接和关闭事件
+	client, connectedList = schema_Connect(1)
+	defer client.Stop()
+
+	select {
+	case remote := <-remoteList:
+		if remote.Version != 1 {
+			t.Fatal("unexpected remote version", remote.Version)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("handshake timeout")
+	}
+
+	select {
+	case <-acceptedList:
+		t.Fatal("incompatible session accepted")
+	case <-connectedList:
+		t.Fatal("incompatible session connected")
+	case msg := <-closedList:
+		t.Fatal("unexpected closed", msg)
+	case <-time.After(time.Millisecond * 300):
+	}
+}
+
+type schema_Session struct {
+	peer.CoreContextSet
+
+	p    cellnet.Peer
+	sent []interface{}
+}
+
+func (self *schema_Session) Raw() interface{}     { return nil }
+func (self *schema_Session) Peer() cellnet.Peer   { return self.p }
+func (self *schema_Session) Send(msg interface{}) { self.sent = append(self.sent, msg) }
+func (self *schema_Session) Close()               {}
+func (self *schema_Session) ID() int64            { return 1 }
+
+// 会话启动后, 连接事件投递前收到对方的握手, 连接事件到达时完成握手
+func TestSchemaEarlyHandshake(t *testing.T) {
+
+	p := peer.NewGenericPeer("tcp.Acceptor", "server", schema_Address, nil)
+	schema.Enable(p, schema.Option{Version: 1})
+
+	ses := &schema_Session{p: p}
+	local := schema.Local(p)
+
+	ev, _ := schema.ResolveInboundEvent(&cellnet.RecvMsgEvent{Ses: ses, Msg: &schema.SchemaHandshake{Version: 1, MetaHash: local.MetaHash}})
+	if ev != nil {
+		t.Fatal("handshake should not be delivered")
+	}
+
+	ev, _ = schema.ResolveInboundEvent(&cellnet.RecvMsgEvent{Ses: ses, Msg: &TestJSONEchoACK{}})
+	if ev != nil {
+		t.Fatal("message before handshake should be dropped")
+	}
+
+	ev, _ = schema.ResolveInboundEvent(&cellnet.RecvMsgEvent{Ses: ses, Msg: &cellnet.SessionAccepted{}})
+	if ev == nil {
+		t.Fatal("expect accepted event")
+	}
+
+	if _, ok := ev.Message().(*cellnet.SessionAccepted); !ok {
+		t.Fatal("unexpected event", ev.Message())
+	}
+
+	if len(ses.sent) != 1 || ses.sent[0] != local {
+		t.Fatal("local handshake not sent", ses.sent)
+	}
+
+	if remote, ok := schema.RemoteOf(ses); !ok || remote.Version != 1 {
+		t.Fatal("unexpected remote", remote)
+	}
+
+	ev, _ = schema.ResolveInboundEvent(&cellnet.RecvMsgEvent{Ses: ses, Msg: &TestJSONEchoACK{}})
+	if ev == nil {
+		t.Fatal("message after handshake should be delivered")
+	}
+}
@@ -0,0 +1,810 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/luis-quan/cellnet"
+	"github.com/luis-quan/cellnet/peer"
+	"github.com/luis-quan/cellnet/proc"
+)
+
+const (
+	broadcast_Address    = "127.0.0.1:7801"
+	group_Address        = "127.0.0.1:7802"
+	sharded_Address      = "127.0.0.1:7803"
+	identity_Address     = "127.0.0.1:7804"
+	closeReason_Address  = "127.0.0.1:7805"
+	closeFlush_Address   = "127.0.0.1:7806"
+	decodeError_Address  = "127.0.0.1:7807"
+	decodeClose_Address  = "127.0.0.1:7808"
+	udpSession_Address   = "127.0.0.1:7809"
+	wsCloseFlush_Address = "127.0.0.1:7810"
+)
+
+// 启动count个客户端, 收到的消息写入通道
+func session_ConnectClients(t *testing.T, address string, count int, recv chan *TestJSONEchoACK) []cellnet.GenericPeer {
+
+	var list []cellnet.GenericPeer
+
+	for i := 0; i < count; i++ {
+
+		p := peer.NewGenericPeer("tcp.SyncConnector", "client", address, nil)
+		proc.BindProcessorHandler(p, "tcp.ltv", func(ev cellnet.Event) {
+			if msg, ok := ev.Message().(*TestJSONEchoACK); ok {
+				recv <- msg
+			}
+		})
+		p.Start()
+
+		if !p.(cellnet.PeerReadyChecker).IsReady() {
+			t.Fatal("client connect failed")
+		}
+
+		list = append(list, p)
+	}
+
+	return list
+}
+
+// 等待接受端的会话数量达到count
+func session_WaitCount(t *testing.T, accessor cellnet.SessionAccessor, count int) {
+
+	for i := 0; i < 100; i++ {
+		if accessor.SessionCount() >= count {
+			return
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatalf("expect %d sessions, got %d", count, accessor.SessionCount())
+}
+
+func session_Collect(t *testing.T, recv chan *TestJSONEchoACK, count int) []*TestJSONEchoACK {
+
+	var list []*TestJSONEchoACK
+
+	for i := 0; i < count; i++ {
+		select {
+		case msg := <-recv:
+			list = append(list, msg)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expect %d messages, got %d", count, len(list))
+		}
+	}
+
+	select {
+	case msg := <-recv:
+		t.Fatalf("unexpected message %+v", msg)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	return list
+}
+
+func TestBroadcast(t *testing.T) {
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", broadcast_Address, nil)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", nil)
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	const clientCount = 4
+
+	for _, p := range session_ConnectClients(t, broadcast_Address, clientCount, recv) {
+		defer p.Stop()
+	}
+
+	accessor := acceptor.(cellnet.SessionAccessor)
+	session_WaitCount(t, accessor, clientCount)
+
+	// 所有会话
+	count, err := peer.Broadcast(accessor, &TestJSONEchoACK{Msg: "all", Value: 1}, nil)
+	if err != nil || count != clientCount {
+		t.Fatalf("broadcast all: count %d, err %v", count, err)
+	}
+
+	for _, msg := range session_Collect(t, recv, clientCount) {
+		if msg.Msg != "all" || msg.Value != 1 {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+
+	// 过滤
+	var idList []int64
+	accessor.VisitSession(func(ses cellnet.Session) bool {
+		idList = append(idList, ses.ID())
+		return true
+	})
+
+	count, err = peer.Broadcast(accessor, &TestJSONEchoACK{Msg: "filter"}, func(ses cellnet.Session) bool {
+		return ses.ID() == idList[0]
+	})
+
+	if err != nil || count != 1 {
+		t.Fatalf("broadcast filter: count %d, err %v", count, err)
+	}
+
+	session_Collect(t, recv, 1)
+
+	// 指定ID, 不存在的ID不计数
+	count, err = peer.BroadcastToIDs(accessor, &TestJSONEchoACK{Msg: "ids"}, []int64{idList[0], idList[1], -1})
+	if err != nil || count != 2 {
+		t.Fatalf("broadcast ids: count %d, err %v", count, err)
+	}
+
+	session_Collect(t, recv, 2)
+
+	// 无法编码的消息
+	if _, err = peer.Broadcast(accessor, struct{}{}, nil); err == nil {
+		t.Fatal("expect encode error")
+	}
+}
+
+func TestSessionGroups(t *testing.T) {
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", group_Address, nil)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", nil)
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	clients := session_ConnectClients(t, group_Address, 3, recv)
+	for _, p := range clients {
+		defer p.Stop()
+	}
+
+	accessor := acceptor.(cellnet.SessionAccessor)
+	session_WaitCount(t, accessor, 3)
+
+	groups := peer.GroupsOf(acceptor)
+
+	var sesList []cellnet.Session
+	accessor.VisitSession(func(ses cellnet.Session) bool {
+		sesList = append(sesList, ses)
+		return true
+	})
+
+	for _, ses := range sesList {
+		groups.Join("room", ses)
+	}
+
+	if groups.Join("room", sesList[0]) {
+		t.Fatal("expect duplicate join failed")
+	}
+
+	groups.Join("other", sesList[0])
+
+	if groups.Count("room") != 3 || len(groups.NamesOf(sesList[0])) != 2 {
+		t.Fatalf("unexpected groups %v", groups.Names())
+	}
+
+	count, err := groups.Broadcast("room", &TestJSONEchoACK{Msg: "room"}, nil)
+	if err != nil || count != 3 {
+		t.Fatalf("broadcast room: count %d, err %v", count, err)
+	}
+
+	session_Collect(t, recv, 3)
+
+	// 离开后不再收到
+	groups.Leave("room", sesList[1])
+
+	count, _ = groups.Broadcast("room", &TestJSONEchoACK{Msg: "room"}, nil)
+	if count != 2 {
+		t.Fatalf("expect 2 members, got %d", count)
+	}
+
+	session_Collect(t, recv, 2)
+
+	// 会话关闭后自动离开所有分组
+	closed := sesList[0]
+	closed.Close()
+
+	for i := 0; i < 100 && groups.Count("room") != 1; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if groups.Count("room") != 1 || groups.Count("other") != 0 || len(groups.NamesOf(closed)) != 0 {
+		t.Fatalf("closed session still in groups %v", groups.Names())
+	}
+
+	// 已经关闭的会话无法加入分组
+	if groups.Join("room", closed) {
+		t.Fatal("closed session should not join")
+	}
+}
+
+func TestShardedSessionManager(t *testing.T) {
+
+	mgr := peer.NewShardedSessionManager(4)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", sharded_Address, nil)
+	acceptor.(cellnet.TCPAcceptor).SetSessionManager(mgr)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", nil)
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	for _, p := range session_ConnectClients(t, sharded_Address, 3, recv) {
+		defer p.Stop()
+	}
+
+	accessor := acceptor.(cellnet.SessionAccessor)
+	session_WaitCount(t, accessor, 3)
+
+	sesList := mgr.Snapshot()
+	if len(sesList) != 3 || mgr.Count() != 3 {
+		t.Fatalf("expect 3 sessions, got %d", len(sesList))
+	}
+
+	// 用户键唯一
+	if err := mgr.SetKey(sesList[0], "account-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.SetKey(sesList[1], "account-1"); err != peer.ErrSessionKeyInUse {
+		t.Fatalf("expect key in use, got %v", err)
+	}
+
+	if mgr.SessionByKey("account-1") != sesList[0] {
+		t.Fatal("session by key mismatch")
+	}
+
+	// 二级索引
+	mgr.SetIndex(sesList[0], "zone", 1)
+	mgr.SetIndex(sesList[1], "zone", 1)
+	mgr.SetIndex(sesList[2], "zone", 2)
+
+	if mgr.IndexCount("zone", 1) != 2 || len(mgr.SessionsByIndex("zone", 2)) != 1 {
+		t.Fatal("unexpected index result")
+	}
+
+	mgr.SetIndex(sesList[1], "zone", 2)
+
+	if mgr.IndexCount("zone", 1) != 1 || mgr.IndexCount("zone", 2) != 2 {
+		t.Fatal("index not moved")
+	}
+
+	// 遍历快照中关闭会话
+	var visited int
+	mgr.VisitSession(func(ses cellnet.Session) bool {
+		if ses == sesList[0] {
+			ses.Close()
+		}
+
+		visited++
+		return true
+	})
+
+	if visited != 3 {
+		t.Fatalf("expect visit 3, got %d", visited)
+	}
+
+	for i := 0; i < 100 && mgr.Count() != 2; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if mgr.Count() != 2 || mgr.SessionByKey("account-1") != nil || mgr.IndexCount("zone", 1) != 0 {
+		t.Fatal("closed session still indexed")
+	}
+
+	if err := mgr.SetKey(sesList[0], "account-1"); err != peer.ErrSessionNotManaged {
+		t.Fatalf("expect not managed, got %v", err)
+	}
+}
+
+// 只实现peer.SessionManager基础接口的外部管理器
+type session_PlainManager struct {
+	core peer.CoreSessionManager
+}
+
+func (self *session_PlainManager) GetSession(id int64) cellnet.Session {
+	return self.core.GetSession(id)
+}
+func (self *session_PlainManager) VisitSession(callback func(cellnet.Session) bool) {
+	self.core.VisitSession(callback)
+}
+func (self *session_PlainManager) SessionCount() int          { return self.core.SessionCount() }
+func (self *session_PlainManager) CloseAllSession()           { self.core.CloseAllSession() }
+func (self *session_PlainManager) Add(ses cellnet.Session)    { self.core.Add(ses) }
+func (self *session_PlainManager) Remove(ses cellnet.Session) { self.core.Remove(ses) }
+func (self *session_PlainManager) Count() int                 { return self.core.Count() }
+func (self *session_PlainManager) SetIDBase(base int64)       { self.core.SetIDBase(base) }
+
+func TestSessionManagerOptional(t *testing.T) {
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", identity_Address, nil)
+
+	if peer.GroupsOf(acceptor) == nil {
+		t.Fatal("core manager should support groups")
+	}
+
+	// 外部管理器不支持分组及用户键
+	acceptor.(cellnet.TCPAcceptor).SetSessionManager(&session_PlainManager{})
+
+	if peer.GroupsOf(acceptor) != nil {
+		t.Fatal("plain manager should not support groups")
+	}
+
+	if peer.SessionByIdentity(acceptor.(cellnet.SessionAccessor), int64(1)) != nil {
+		t.Fatal("plain manager should not support keys")
+	}
+}
+
+func TestUDPSessionManager(t *testing.T) {
+
+	recv := make(chan cellnet.Session, 1)
+
+	acceptor := peer.NewGenericPeer("udp.Acceptor", "server", udpSession_Address, nil)
+	proc.BindProcessorHandler(acceptor, "udp.ltv", func(ev cellnet.Event) {
+		if _, ok := ev.Message().(*TestJSONEchoACK); ok {
+			recv <- ev.Session()
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	connector := peer.NewGenericPeer("udp.Connector", "client", udpSession_Address, nil)
+	proc.BindProcessorHandler(connector, "udp.ltv", func(ev cellnet.Event) {
+		if _, ok := ev.Message().(*cellnet.SessionConnected); ok {
+			ev.Session().Send(&TestJSONEchoACK{Msg: "udp"})
+		}
+	})
+	connector.Start()
+	defer connector.Stop()
+
+	var ses cellnet.Session
+	select {
+	case ses = <-recv:
+	case <-time.After(time.Second * 3):
+		t.Fatal("udp message timeout")
+	}
+
+	// udp会话同样由会话管理器分配ID, 可以按ID访问及加入分组
+	accessor := acceptor.(cellnet.SessionAccessor)
+	if ses.ID() == 0 || accessor.GetSession(ses.ID()) != ses || accessor.SessionCount() != 1 {
+		t.Fatalf("udp session not managed, id %d, count %d", ses.ID(), accessor.SessionCount())
+	}
+
+	if !peer.GroupsOf(acceptor).Join("room", ses) {
+		t.Fatal("udp session join group failed")
+	}
+
+	if connector.(cellnet.SessionAccessor).SessionCount() != 1 {
+		t.Fatal("udp connector session not managed")
+	}
+}
+
+func TestSessionIdentity(t *testing.T) {
+
+	closedList := make(chan cellnet.Session, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", identity_Address, nil)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		if _, ok := ev.Message().(*cellnet.SessionClosed); ok {
+			closedList <- ev.Session()
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	for _, p := range session_ConnectClients(t, identity_Address, 2, recv) {
+		defer p.Stop()
+	}
+
+	accessor := acceptor.(cellnet.SessionAccessor)
+	session_WaitCount(t, accessor, 2)
+
+	var sesList []cellnet.Session
+	accessor.VisitSession(func(ses cellnet.Session) bool {
+		sesList = append(sesList, ses)
+		return true
+	})
+
+	oldSes, newSes := sesList[0], sesList[1]
+
+	if _, err := peer.Bind(oldSes, int64(1001), peer.Duplicate_RejectNew); err != nil {
+		t.Fatal(err)
+	}
+
+	// 拒绝新会话
+	if _, err := peer.Bind(newSes, int64(1001), peer.Duplicate_RejectNew); err != peer.ErrIdentityInUse {
+		t.Fatalf("expect identity in use, got %v", err)
+	}
+
+	// 踢掉旧会话
+	kicked, err := peer.Bind(newSes, int64(1001), peer.Duplicate_KickOld)
+	if err != nil || kicked != oldSes {
+		t.Fatalf("expect old session kicked, got %v %v", kicked, err)
+	}
+
+	if peer.SessionByIdentity(accessor, int64(1001)) != newSes {
+		t.Fatal("session by identity mismatch")
+	}
+
+	select {
+	case ses := <-closedList:
+		if ses != oldSes {
+			t.Fatalf("unexpected closed session %d", ses.ID())
+		}
+
+		// 关闭后仍然可以获取被踢会话的身份
+		if key, ok := peer.IdentityOf(ses); !ok || key != int64(1001) {
+			t.Fatalf("unexpected identity %v", key)
+		}
+
+	case <-time.After(time.Second * 5):
+		t.Fatal("kicked session not closed")
+	}
+
+	// 会话关闭后自动解除绑定
+	newSes.Close()
+
+	for i := 0; i < 100 && peer.SessionByIdentity(accessor, int64(1001)) != nil; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if peer.SessionByIdentity(accessor, int64(1001)) != nil {
+		t.Fatal("identity not unbound after close")
+	}
+}
+
+// 连接到address, 返回连接端及连接端收到的SessionClosed
+func session_Connect(t *testing.T, address string) (cellnet.GenericPeer, chan *cellnet.SessionClosed) {
+
+	connectedList := make(chan cellnet.Session, 1)
+	closedList := make(chan *cellnet.SessionClosed, 1)
+
+	p := peer.NewGenericPeer("tcp.Connector", "client", address, nil)
+	proc.BindProcessorHandler(p, "tcp.ltv", func(ev cellnet.Event) {
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionConnected:
+			connectedList <- ev.Session()
+		case *cellnet.SessionClosed:
+			closedList <- msg
+		}
+	})
+	p.Start()
+
+	select {
+	case <-connectedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("connect timeout")
+	}
+
+	return p, closedList
+}
+
+func session_ExpectClosed(t *testing.T, closedList chan *cellnet.SessionClosed, reason cellnet.CloseReason, initiator cellnet.CloseInitiator, code int32) *cellnet.SessionClosed {
+
+	select {
+	case msg := <-closedList:
+		if msg.Reason != reason || msg.Initiator != initiator || msg.Code != code {
+			t.Fatalf("expect %s %s %d, got %+v", reason, initiator, code, msg)
+		}
+
+		return msg
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expect %s closed, timeout", reason)
+	}
+
+	return nil
+}
+
+func TestSessionCloseReason(t *testing.T) {
+
+	acceptedList := make(chan cellnet.Session, 10)
+	closedList := make(chan *cellnet.SessionClosed, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", closeReason_Address, nil)
+	acceptor.(cellnet.TCPSocketOption).SetMaxPacketSize(64)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionAccepted:
+			acceptedList <- ev.Session()
+		case *cellnet.SessionClosed:
+			closedList <- msg
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	// 服务器踢掉客户端, 关闭码随关闭帧发给对方
+	client, clientClosed := session_Connect(t, closeReason_Address)
+
+	select {
+	case ses := <-acceptedList:
+		cellnet.CloseSession(ses, cellnet.CloseReason_Kicked, 4001)
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	session_ExpectClosed(t, closedList, cellnet.CloseReason_Kicked, cellnet.CloseInitiator_Local, 4001)
+	session_ExpectClosed(t, clientClosed, cellnet.CloseReason_Kicked, cellnet.CloseInitiator_Remote, 4001)
+	client.Stop()
+
+	// 客户端发送超长封包, 服务器断开并通知原因
+	client, clientClosed = session_Connect(t, closeReason_Address)
+	<-acceptedList
+
+	client.(cellnet.TCPConnector).Session().Send(&TestJSONEchoACK{Msg: string(make([]byte, 128))})
+
+	if msg := session_ExpectClosed(t, closedList, cellnet.CloseReason_MaxPacketExceeded, cellnet.CloseInitiator_Local, 0); msg.Err == nil {
+		t.Fatal("expect error")
+	}
+
+	session_ExpectClosed(t, clientClosed, cellnet.CloseReason_MaxPacketExceeded, cellnet.CloseInitiator_Remote, 0)
+	client.Stop()
+
+	// 客户端停止
+	client, _ = session_Connect(t, closeReason_Address)
+	<-acceptedList
+
+	client.Stop()
+
+	session_ExpectClosed(t, closedList, cellnet.CloseReason_PeerStopping, cellnet.CloseInitiator_Remote, 0)
+}
+
+func TestSessionCloseFlush(t *testing.T) {
+
+	acceptedList := make(chan cellnet.Session, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", closeFlush_Address, nil)
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		if _, ok := ev.Message().(*cellnet.SessionAccepted); ok {
+			acceptedList <- ev.Session()
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	recv := make(chan *TestJSONEchoACK, 200)
+	closedList := make(chan *cellnet.SessionClosed, 10)
+
+	connector := peer.NewGenericPeer("tcp.Connector", "client", closeFlush_Address, nil)
+	connector.(cellnet.TCPConnector).SetReconnectDuration(time.Millisecond * 50)
+	proc.BindProcessorHandler(connector, "tcp.ltv", func(ev cellnet.Event) {
+		switch msg := ev.Message().(type) {
+		case *TestJSONEchoACK:
+			recv <- msg
+		case *cellnet.SessionClosed:
+			closedList <- msg
+		}
+	})
+	connector.Start()
+	defer connector.Stop()
+
+	var ses cellnet.Session
+	select {
+	case ses = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	// 断开前的消息全部送达
+	for i := 0; i < 100; i++ {
+		ses.Send(&TestJSONEchoACK{Msg: "data"})
+	}
+
+	ses.(cellnet.SessionFlushCloser).CloseAfterFlush(time.Second)
+
+	// 关闭后发送的消息被丢弃
+	ses.Send(&TestJSONEchoACK{Msg: "after close"})
+
+	if len(session_Collect(t, recv, 100)) != 100 {
+		t.Fatal("message lost before close")
+	}
+
+	session_ExpectClosed(t, closedList, cellnet.CloseReason_Manual, cellnet.CloseInitiator_Remote, 0)
+
+	// 重连后发送最后一条消息并断开
+	select {
+	case ses = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("reconnect timeout")
+	}
+
+	ses.(cellnet.SessionFlushCloser).CloseWithMessage(&TestJSONEchoACK{Msg: "kicked"})
+
+	resume_Expect(t, recv, "kicked")
+	session_ExpectClosed(t, closedList, cellnet.CloseReason_Manual, cellnet.CloseInitiator_Remote, 0)
+}
+
+func TestSessionCloseFlushWebSocket(t *testing.T) {
+
+	acceptedList := make(chan cellnet.Session, 10)
+	serverClosed := make(chan *cellnet.SessionClosed, 10)
+
+	acceptor := peer.NewGenericPeer("gorillaws.Acceptor", "server", wsCloseFlush_Address, nil)
+	proc.BindProcessorHandler(acceptor, "gorillaws.ltv", func(ev cellnet.Event) {
+		switch msg := ev.Message().(type) {
+		case *cellnet.SessionAccepted:
+			acceptedList <- ev.Session()
+		case *cellnet.SessionClosed:
+			serverClosed <- msg
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	// 消息及关闭事件按到达顺序记录
+	events := make(chan interface{}, 200)
+
+	connector := peer.NewGenericPeer("gorillaws.Connector", "client", wsCloseFlush_Address, nil)
+	proc.BindProcessorHandler(connector, "gorillaws.ltv", func(ev cellnet.Event) {
+		switch msg := ev.Message().(type) {
+		case *TestJSONEchoACK, *cellnet.SessionClosed:
+			events <- msg
+		}
+	})
+	connector.Start()
+	defer connector.Stop()
+
+	var ses cellnet.Session
+	select {
+	case ses = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	for i := 0; i < 100; i++ {
+		ses.Send(&TestJSONEchoACK{Msg: "data"})
+	}
+
+	ses.(cellnet.SessionFlushCloser).CloseWithMessage(&TestJSONEchoACK{Msg: "kicked"})
+
+	// 最后的消息在SessionClosed之前送达
+	var count int
+	for closed := false; !closed; {
+		select {
+		case raw := <-events:
+			switch msg := raw.(type) {
+			case *TestJSONEchoACK:
+				count++
+				if count == 101 && msg.Msg != "kicked" {
+					t.Fatalf("expect kicked, got %s", msg.Msg)
+				}
+			case *cellnet.SessionClosed:
+				if count != 101 {
+					t.Fatalf("closed before flush, got %d messages", count)
+				}
+
+				if msg.Reason != cellnet.CloseReason_Manual || msg.Initiator != cellnet.CloseInitiator_Remote || msg.Code != 0 {
+					t.Fatalf("unexpected closed %+v", msg)
+				}
+
+				closed = true
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expect closed, got %d messages", count)
+		}
+	}
+
+	session_ExpectClosed(t, serverClosed, cellnet.CloseReason_Manual, cellnet.CloseInitiator_Local, 0)
+
+	// 对方不回应websocket关闭消息时, 超时后强制断开
+	dialer := websocket.Dialer{}
+	silent, _, err := dialer.Dial("ws://"+wsCloseFlush_Address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer silent.Close()
+
+	select {
+	case ses = <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	start := time.Now()
+	ses.(cellnet.SessionFlushCloser).CloseAfterFlush(time.Millisecond * 200)
+
+	session_ExpectClosed(t, serverClosed, cellnet.CloseReason_Manual, cellnet.CloseInitiator_Local, 0)
+
+	if time.Since(start) < time.Millisecond*200 {
+		t.Fatal("closed before flush timeout")
+	}
+
+	// 对方直接发送websocket关闭消息, 关闭码作为SessionClosed的Code
+	remote, _, err := dialer.Dial("ws://"+wsCloseFlush_Address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer remote.Close()
+
+	select {
+	case <-acceptedList:
+	case <-time.After(time.Second * 5):
+		t.Fatal("accept timeout")
+	}
+
+	remote.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(4001, "bye"), time.Now().Add(time.Second))
+
+	session_ExpectClosed(t, serverClosed, cellnet.CloseReason_IO, cellnet.CloseInitiator_Remote, 4001)
+}
+
+func TestDecodeErrorPolicy(t *testing.T) {
+
+	meta := cellnet.MessageMetaByType(reflect.TypeOf((*TestJSONEchoACK)(nil)).Elem())
+
+	// 未注册的消息ID
+	unknownID := 1
+	for cellnet.MessageMetaByID(unknownID) != nil {
+		unknownID++
+	}
+
+	decodeErrList := make(chan *cellnet.DecodeError, 10)
+	recv := make(chan *TestJSONEchoACK, 10)
+
+	acceptor := peer.NewGenericPeer("tcp.Acceptor", "server", decodeError_Address, nil)
+	peer.SetDecodeErrorPolicy(acceptor, peer.DecodeErrorOption{
+		DecodeFailed: peer.DecodeError_Deliver,
+		UnknownID:    peer.DecodeError_Drop,
+	})
+	proc.BindProcessorHandler(acceptor, "tcp.ltv", func(ev cellnet.Event) {
+		switch msg := ev.Message().(type) {
+		case *cellnet.DecodeError:
+			decodeErrList <- msg
+		case *TestJSONEchoACK:
+			recv <- msg
+		}
+	})
+	acceptor.Start()
+	defer acceptor.Stop()
+
+	client, _ := session_Connect(t, decodeError_Address)
+	defer client.Stop()
+
+	clientSes := client.(cellnet.TCPConnector).Session()
+	clientSes.Send(&cellnet.RawPacket{MsgID: unknownID, MsgData: []byte("unknown")})
+	clientSes.Send(&cellnet.RawPacket{MsgID: meta.ID, MsgData: []byte("{bad json")})
+	clientSes.Send(&TestJSONEchoACK{Msg: "ok"})
+
+	select {
+	case msg := <-decodeErrList:
+		if msg.MsgID != meta.ID || msg.Size != len("{bad json") || msg.Err == nil {
+			t.Fatalf("unexpected decode error %+v", msg)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("decode error not delivered")
+	}
+
+	// 解码错误后连接保持
+	resume_Expect(t, recv, "ok")
+
+	if failed, unknown := peer.DecodeErrorCount(acceptor); failed != 1 || unknown != 1 {
+		t.Fatalf("unexpected decode error count %d %d", failed, unknown)
+	}
+
+	// 收到未注册的消息时断开
+	closedList := make(chan *cellnet.SessionClosed, 10)
+
+	closeAcceptor := peer.NewGenericPeer("tcp.Acceptor", "server", decodeClose_Address, nil)
+	peer.SetDecodeErrorPolicy(closeAcceptor, peer.DecodeErrorOption{UnknownID: peer.DecodeError_Disconnect})
+	proc.BindProcessorHandler(closeAcceptor, "tcp.ltv", func(ev cellnet.Event) {
+		if msg, ok := ev.Message().(*cellnet.SessionClosed); ok {
+			closedList <- msg
+		}
+	})
+	closeAcceptor.Start()
+	defer closeAcceptor.Stop()
+
+	closeClient, clientClosed := session_Connect(t, decodeClose_Address)
+	defer closeClient.Stop()
+
+	closeClient.(cellnet.TCPConnector).Session().Send(&cellnet.RawPacket{MsgID: unknownID, MsgData: []byte("unknown")})
+
+	session_ExpectClosed(t, closedList, cellnet.CloseReason_DecodeError, cellnet.CloseInitiator_Local, 0)
+	session_ExpectClosed(t, clientClosed, cellnet.CloseReason_DecodeError, cellnet.CloseInitiator_Remote, 0)
+}
@@ -65,3 +65,70 @@ func TestLoopTimer(t *testing.T) {
 
 	signal.WaitAndExpect("10ms * 10 times ticker not done", 1)
 }
+
+func TestScheduleTimer(t *testing.T) {
+
+	signal := NewSignalTester(t)
+	signal.SetTimeout(5 * time.Second)
+
+	queue := cellnet.NewEventQueue()
+
+	queue.StartLoop()
+
+	// 每秒触发
+	sched, err := timer.NewCronSchedule(queue, "* * * * * *", func(s *timer.Schedule) {
+
+		log.Debugln("schedule", s.Planned, s.Missed)
+
+		s.Stop()
+		signal.Done(1)
+	}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sched.Start()
+
+	signal.WaitAndExpect("schedule not fired", 1)
+}
+
+func TestFixedRateLoop(t *testing.T) {
+
+	for _, policy := range []timer.MissedTickPolicy{timer.MissedTick_Skip, timer.MissedTick_Coalesce} {
+
+		signal := NewSignalTester(t)
+
+		queue := cellnet.NewEventQueue()
+
+		queue.StartLoop()
+
+		var count int
+
+		timer.NewLoop(queue, time.Millisecond*20, func(ctx *timer.Loop) {
+
+			count++
+
+			log.Debugln("fixed rate tick", count, ctx.Delta, ctx.Missed, ctx.Lag)
+
+			switch count {
+			case 1:
+				// 阻塞队列, 造成后续tick错过
+				time.Sleep(time.Millisecond * 70)
+			case 2:
+				ctx.Stop()
+
+				if ctx.Missed > 0 {
+					signal.Done(1)
+				} else {
+					signal.Done(0)
+				}
+			}
+
+		}, nil).SetFixedRate(policy).Start()
+
+		signal.WaitAndExpect("missed tick not reported", 1)
+
+		queue.StopLoop()
+	}
+}
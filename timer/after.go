@@ -10,12 +10,12 @@ type AfterStopper interface {
 	Stop() bool
 }
 
-// 在给定的duration持续时间后, 执行callbackObj对象类型对应的函数回调
+// 在给定的duration持续时间后, 执行callbackObj对象类型对应的函数回调, 时间由全局时钟决定
 // q: 队列,在指定的队列goroutine执行, 空时,直接在当前goroutine
 // context: 将context上下文传递到带有context指针的函数回调中
 func After(q cellnet.EventQueue, duration time.Duration, callbackObj interface{}, context interface{}) AfterStopper {
 
-	return time.AfterFunc(duration, func() {
+	return CurrentClock().AfterFunc(duration, func() {
 		switch callback := callbackObj.(type) {
 		case func():
 			if callback != nil {
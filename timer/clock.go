@@ -0,0 +1,81 @@
+package timer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 时钟, timer, rpc超时及socket读写超时均通过当前时钟获取时间和创建定时器
+// 测试时使用SetClock替换为FakeClock, 手动推进时间, 使超时和tick确定性触发
+type Clock interface {
+	// 当前时间
+	Now() time.Time
+
+	// 在duration后, 在独立goroutine(或推进时间的goroutine)调用f
+	AfterFunc(duration time.Duration, f func()) AfterStopper
+
+	// 在duration后, 向返回的通道发送当前时间
+	After(duration time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(duration time.Duration, f func()) AfterStopper {
+	return time.AfterFunc(duration, f)
+}
+
+func (systemClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+// 使用系统时间的时钟, 默认时钟
+var SystemClock Clock = systemClock{}
+
+type clockHolder struct {
+	Clock
+}
+
+var currentClock atomic.Value
+
+func init() {
+	currentClock.Store(clockHolder{SystemClock})
+}
+
+// 设置全局时钟, 传入nil时恢复为SystemClock
+func SetClock(c Clock) {
+
+	if c == nil {
+		c = SystemClock
+	}
+
+	currentClock.Store(clockHolder{c})
+}
+
+// 获取全局时钟
+func CurrentClock() Clock {
+	return currentClock.Load().(clockHolder).Clock
+}
+
+// 当前全局时钟是否为系统时钟
+func IsSystemClock() bool {
+	return CurrentClock() == SystemClock
+}
+
+// 全局时钟的当前时间
+func Now() time.Time {
+	return CurrentClock().Now()
+}
+
+// 全局时钟下, 从t开始经过的时间
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+// 全局时钟下, 到t还需要的时间
+func Until(t time.Time) time.Duration {
+	return t.Sub(Now())
+}
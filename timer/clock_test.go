@@ -0,0 +1,78 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAfter(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	SetClock(clock)
+	defer SetClock(nil)
+
+	var fired []int
+
+	After(nil, time.Second*2, func() {
+		fired = append(fired, 2)
+	}, nil)
+
+	After(nil, time.Second, func(context interface{}) {
+		fired = append(fired, context.(int))
+	}, 1)
+
+	stopper := After(nil, time.Second*3, func() {
+		fired = append(fired, 3)
+	}, nil)
+
+	clock.Advance(time.Millisecond * 999)
+	if len(fired) != 0 {
+		t.Fatalf("fired too early: %v", fired)
+	}
+
+	clock.Advance(time.Millisecond * 1001)
+	if len(fired) != 2 || fired[0] != 1 || fired[1] != 2 {
+		t.Fatalf("unexpected fire order: %v", fired)
+	}
+
+	if !stopper.Stop() || clock.PendingTimers() != 0 {
+		t.Fatal("stop pending timer failed")
+	}
+
+	clock.Advance(time.Hour)
+	if len(fired) != 2 {
+		t.Fatalf("stopped timer fired: %v", fired)
+	}
+}
+
+func TestFakeClockLoop(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	SetClock(clock)
+	defer SetClock(nil)
+
+	var count int
+
+	NewLoop(nil, time.Millisecond*50, func(loop *Loop) {
+		count++
+
+		if count == 10 {
+			loop.Stop()
+		}
+	}, nil).SetFixedRate(MissedTick_Coalesce).Start()
+
+	// 逐个节拍推进
+	for i := 0; i < 10; i++ {
+		clock.Advance(time.Millisecond * 50)
+	}
+
+	if count != 10 {
+		t.Fatalf("expect 10 ticks, got %d", count)
+	}
+
+	clock.Advance(time.Second)
+
+	if count != 10 {
+		t.Fatalf("tick after stop, got %d", count)
+	}
+}
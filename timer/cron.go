@@ -0,0 +1,405 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日程规则, 给定时间点, 返回严格晚于该时间的下一次触发时间, 无下一次时返回零值
+// 规则按传入时间所在的时区(time.Location)计算
+type ScheduleRule interface {
+	Next(t time.Time) time.Time
+}
+
+// 将普通函数转换为日程规则
+type ScheduleRuleFunc func(t time.Time) time.Time
+
+func (self ScheduleRuleFunc) Next(t time.Time) time.Time {
+	return self(t)
+}
+
+var (
+	ErrInvalidCron = errors.New("timer: invalid cron expression")
+)
+
+// cron规则, 每个字段使用位图表示允许的值
+type cronRule struct {
+	second, minute, hour, dom, month, dow uint64
+
+	// 日期和星期同时指定时, 满足其一即可(与标准cron一致)
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = cronField{0, 59, nil}
+	minuteField = cronField{0, 59, nil}
+	hourField   = cronField{0, 23, nil}
+	domField    = cronField{1, 31, nil}
+	monthField  = cronField{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{0, 6, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// 解析cron表达式
+// 支持5段(分 时 日 月 周)及6段(秒 分 时 日 月 周)格式, 支持 * , - / 及月份和星期的英文缩写
+// 支持@yearly @monthly @weekly @daily @hourly描述符
+func ParseCron(expr string) (ScheduleRule, error) {
+
+	expr = strings.TrimSpace(expr)
+
+	if desc, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = desc
+	}
+
+	fields := strings.Fields(expr)
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%s: '%s' require 5 or 6 fields", ErrInvalidCron, expr)
+	}
+
+	var (
+		rule cronRule
+		err  error
+	)
+
+	if rule.second, err = secondField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if rule.minute, err = minuteField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if rule.hour, err = hourField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if rule.dom, err = domField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+
+	if rule.month, err = monthField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	// 周日允许写作7
+	dow := fields[5]
+	if rule.dow, err = (cronField{0, 7, dowField.names}).parse(dow); err != nil {
+		return nil, err
+	}
+
+	if rule.dow&(1<<7) != 0 {
+		rule.dow = rule.dow&^(1<<7) | 1
+	}
+
+	rule.domStar = fields[3] == "*" || fields[3] == "?"
+	rule.dowStar = dow == "*" || dow == "?"
+
+	return &rule, nil
+}
+
+// 解析cron表达式, 出错时崩溃, 用于初始化时的固定表达式
+func MustParseCron(expr string) ScheduleRule {
+	rule, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	return rule
+}
+
+// 每天的给定时间触发
+func Daily(hour, min, sec int) ScheduleRule {
+	return &cronRule{
+		second:  1 << uint(sec),
+		minute:  1 << uint(min),
+		hour:    1 << uint(hour),
+		dom:     domField.all(),
+		month:   monthField.all(),
+		dow:     dowField.all(),
+		domStar: true,
+		dowStar: true,
+	}
+}
+
+// 每周给定星期的给定时间触发
+func Weekly(weekday time.Weekday, hour, min, sec int) ScheduleRule {
+	return &cronRule{
+		second:  1 << uint(sec),
+		minute:  1 << uint(min),
+		hour:    1 << uint(hour),
+		dom:     domField.all(),
+		month:   monthField.all(),
+		dow:     1 << uint(weekday),
+		domStar: true,
+	}
+}
+
+// 每月给定日期的给定时间触发, 当月没有该日期时跳过
+func Monthly(day, hour, min, sec int) ScheduleRule {
+	return &cronRule{
+		second:  1 << uint(sec),
+		minute:  1 << uint(min),
+		hour:    1 << uint(hour),
+		dom:     1 << uint(day),
+		month:   monthField.all(),
+		dow:     dowField.all(),
+		dowStar: true,
+	}
+}
+
+func (self cronField) all() (bits uint64) {
+	for i := self.min; i <= self.max; i++ {
+		bits |= 1 << uint(i)
+	}
+
+	return
+}
+
+func (self cronField) value(s string) (int, error) {
+
+	if v, ok := self.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value '%s'", ErrInvalidCron, s)
+	}
+
+	if v < self.min || v > self.max {
+		return 0, fmt.Errorf("%s: value %d out of range [%d, %d]", ErrInvalidCron, v, self.min, self.max)
+	}
+
+	return v, nil
+}
+
+// 解析单个字段, 格式如: *  */5  1-5  1,3,5  1-10/2  mon-fri
+func (self cronField) parse(s string) (bits uint64, err error) {
+
+	for _, part := range strings.Split(s, ",") {
+
+		var (
+			begin, end int
+			step       = 1
+		)
+
+		rangePart := part
+		if pos := strings.Index(part, "/"); pos != -1 {
+			rangePart = part[:pos]
+
+			step, err = strconv.Atoi(part[pos+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: invalid step '%s'", ErrInvalidCron, part)
+			}
+		}
+
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			begin, end = self.min, self.max
+		case strings.Contains(rangePart, "-"):
+			pair := strings.SplitN(rangePart, "-", 2)
+
+			if begin, err = self.value(pair[0]); err != nil {
+				return
+			}
+
+			if end, err = self.value(pair[1]); err != nil {
+				return
+			}
+
+			if begin > end {
+				return 0, fmt.Errorf("%s: invalid range '%s'", ErrInvalidCron, part)
+			}
+		default:
+			if begin, err = self.value(rangePart); err != nil {
+				return
+			}
+
+			// 5/10 表示从5开始每10个单位
+			if step > 1 {
+				end = self.max
+			} else {
+				end = begin
+			}
+		}
+
+		for i := begin; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return
+}
+
+func (self *cronRule) dayMatches(t time.Time) bool {
+
+	domMatch := self.dom&(1<<uint(t.Day())) != 0
+	dowMatch := self.dow&(1<<uint(t.Weekday())) != 0
+
+	if self.domStar || self.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// 查找下一个匹配的时间, 按时间t所在时区计算
+// 夏令时切换时: 不存在的本地时间会被跳过, 重复出现的本地时间只触发第一次
+func (self *cronRule) Next(t time.Time) time.Time {
+
+	next := self.next(t)
+
+	// 回拨时重复的本地时间, 只取第一次
+	for !next.IsZero() && isRepeatedWallTime(next) {
+		next = self.next(next)
+	}
+
+	return next
+}
+
+func (self *cronRule) next(t time.Time) time.Time {
+
+	loc := t.Location()
+
+	// 从下一秒开始
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+
+	added := false
+
+	// 5年内没有匹配时, 认为规则无法满足(例如2月30日)
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for self.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+
+		t = t.AddDate(0, 1, 0)
+
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !self.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+
+		t = t.AddDate(0, 0, 1)
+
+		// 夏令时导致午夜不存在时, 修正到当天开始
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-t.Hour()) * time.Hour)
+			}
+		}
+
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for self.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+
+		t = t.Add(time.Hour)
+
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for self.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+
+		t = t.Add(time.Minute)
+
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for self.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+
+		t = t.Add(time.Second)
+
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t
+}
+
+// 时钟回拨后, 给定时间的本地时间是否已经出现过
+func isRepeatedWallTime(t time.Time) bool {
+
+	_, offset := t.Zone()
+
+	// 时区偏移变化一般不超过3小时
+	_, prevOffset := t.Add(-3 * time.Hour).Zone()
+
+	if prevOffset <= offset {
+		return false
+	}
+
+	earlier := t.Add(-time.Duration(prevOffset-offset) * time.Second)
+
+	_, earlierOffset := earlier.Zone()
+
+	return earlierOffset == prevOffset && sameWallClock(earlier, t)
+}
+
+func sameWallClock(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd &&
+		a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second()
+}
@@ -0,0 +1,174 @@
+package timer
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// 手动推进的时钟, 用于确定性测试
+// 定时器回调在调用Advance/Set的goroutine中执行
+type FakeClock struct {
+	now    time.Time
+	timers fakeTimerHeap
+	seq    int64
+
+	guard sync.Mutex
+	cond  *sync.Cond
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	seq      int64 // 相同时间时, 按创建顺序触发
+	index    int   // 在堆中的位置, -1表示已经触发或停止
+	callback func()
+}
+
+func (self *fakeTimer) Stop() bool {
+
+	self.clock.guard.Lock()
+	defer self.clock.guard.Unlock()
+
+	if self.index < 0 {
+		return false
+	}
+
+	heap.Remove(&self.clock.timers, self.index)
+	self.clock.cond.Broadcast()
+
+	return true
+}
+
+func (self *FakeClock) Now() time.Time {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+	return self.now
+}
+
+func (self *FakeClock) AfterFunc(duration time.Duration, f func()) AfterStopper {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	self.seq++
+
+	t := &fakeTimer{
+		clock:    self,
+		deadline: self.now.Add(duration),
+		seq:      self.seq,
+		callback: f,
+	}
+
+	heap.Push(&self.timers, t)
+	self.cond.Broadcast()
+
+	return t
+}
+
+func (self *FakeClock) After(duration time.Duration) <-chan time.Time {
+
+	c := make(chan time.Time, 1)
+
+	self.AfterFunc(duration, func() {
+		c <- self.Now()
+	})
+
+	return c
+}
+
+// 推进时间, 按顺序触发到期的定时器(包括回调中新建的到期定时器)
+func (self *FakeClock) Advance(duration time.Duration) {
+	self.Set(self.Now().Add(duration))
+}
+
+// 将时间设置到t, 时间不能回退
+func (self *FakeClock) Set(t time.Time) {
+
+	for {
+		self.guard.Lock()
+
+		if len(self.timers) == 0 || self.timers[0].deadline.After(t) {
+			if t.After(self.now) {
+				self.now = t
+			}
+
+			self.guard.Unlock()
+			return
+		}
+
+		timer := heap.Pop(&self.timers).(*fakeTimer)
+
+		if timer.deadline.After(self.now) {
+			self.now = timer.deadline
+		}
+
+		self.cond.Broadcast()
+		self.guard.Unlock()
+
+		timer.callback()
+	}
+}
+
+// 等待中的定时器数量
+func (self *FakeClock) PendingTimers() int {
+	self.guard.Lock()
+	defer self.guard.Unlock()
+	return len(self.timers)
+}
+
+// 阻塞直到等待中的定时器数量不小于count, 用于等待其他goroutine创建定时器(例如rpc.CallSync)
+func (self *FakeClock) BlockUntil(count int) {
+
+	self.guard.Lock()
+	defer self.guard.Unlock()
+
+	for len(self.timers) < count {
+		self.cond.Wait()
+	}
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+
+	self := &FakeClock{
+		now: start,
+	}
+
+	self.cond = sync.NewCond(&self.guard)
+
+	return self
+}
+
+type fakeTimerHeap []*fakeTimer
+
+func (self fakeTimerHeap) Len() int { return len(self) }
+
+func (self fakeTimerHeap) Less(i, j int) bool {
+	if self[i].deadline.Equal(self[j].deadline) {
+		return self[i].seq < self[j].seq
+	}
+
+	return self[i].deadline.Before(self[j].deadline)
+}
+
+func (self fakeTimerHeap) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+	self[i].index = i
+	self[j].index = j
+}
+
+func (self *fakeTimerHeap) Push(x interface{}) {
+	t := x.(*fakeTimer)
+	t.index = len(*self)
+	*self = append(*self, t)
+}
+
+func (self *fakeTimerHeap) Pop() interface{} {
+	old := *self
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*self = old[:n-1]
+	return t
+}
@@ -7,6 +7,14 @@ import (
 	"github.com/luis-quan/cellnet"
 )
 
+// 固定频率模式下, 错过tick时的处理策略
+type MissedTickPolicy int
+
+const (
+	MissedTick_Skip     MissedTickPolicy = iota // 跳过错过的tick, 在下一个原有节拍回调
+	MissedTick_Coalesce                         // 错过的tick合并为一次立即回调, 之后从当前时间重新计算节拍
+)
+
 // 轻量级的持续Tick循环
 type Loop struct {
 	Context        interface{}
@@ -16,6 +24,21 @@ type Loop struct {
 	running int64
 
 	Queue cellnet.EventQueue
+
+	// 距上次回调实际经过的时间
+	Delta time.Duration
+
+	// 固定频率模式下, 本次回调前错过的tick数量
+	Missed int
+
+	// 固定频率模式下, 本次回调相对计划时间的延迟
+	Lag time.Duration
+
+	fixedRate    bool
+	missedPolicy MissedTickPolicy
+	deadline     time.Time
+	lastTick     time.Time
+	pendingMiss  int
 }
 
 func (self *Loop) Running() bool {
@@ -41,11 +64,27 @@ func (self *Loop) Start() bool {
 
 	atomic.StoreInt64(&self.running, 1)
 
+	now := Now()
+	self.lastTick = now
+	self.deadline = now.Add(self.Duration)
+	self.pendingMiss = 0
+
 	self.rawPost()
 
 	return true
 }
 
+// 开启固定频率模式, 按绝对时间点调度, 回调耗时不会造成节拍漂移
+func (self *Loop) SetFixedRate(policy MissedTickPolicy) *Loop {
+	self.fixedRate = true
+	self.missedPolicy = policy
+	return self
+}
+
+func (self *Loop) FixedRate() bool {
+	return self.fixedRate
+}
+
 func (self *Loop) rawPost() {
 
 	if self.Duration == 0 {
@@ -53,13 +92,54 @@ func (self *Loop) rawPost() {
 	}
 
 	if self.Running() {
-		After(self.Queue, self.Duration, func() {
+
+		wait := self.Duration
+
+		if self.fixedRate {
+			wait = Until(self.deadline)
+			if wait < 0 {
+				wait = 0
+			}
+		}
+
+		After(self.Queue, wait, func() {
 
 			tick(self, false)
 		}, nil)
 	}
 }
 
+// 计算固定频率模式下的下一个节拍, 返回本次是否需要回调
+func (self *Loop) advanceFixedRate(now time.Time) bool {
+
+	lag := now.Sub(self.deadline)
+	if lag < 0 {
+		lag = 0
+	}
+
+	missed := int(lag / self.Duration)
+
+	if missed > 0 && self.missedPolicy == MissedTick_Skip {
+
+		// 本次及错过的tick都跳过, 对齐到原有节拍
+		self.pendingMiss += missed + 1
+		self.deadline = self.deadline.Add(self.Duration * time.Duration(missed+1))
+		return false
+	}
+
+	self.Missed = self.pendingMiss + missed
+	self.Lag = lag
+	self.pendingMiss = 0
+
+	if missed > 0 {
+		self.deadline = now.Add(self.Duration)
+	} else {
+		self.deadline = self.deadline.Add(self.Duration)
+	}
+
+	return true
+}
+
 func (self *Loop) NextLoop() {
 
 	self.Queue.Post(func() {
@@ -96,12 +176,25 @@ func tick(ctx interface{}, nextLoop bool) {
 
 	loop := ctx.(*Loop)
 
+	now := Now()
+
 	if !nextLoop && loop.Running() {
 
+		if loop.fixedRate && !loop.advanceFixedRate(now) {
+			loop.rawPost()
+			return
+		}
+
 		// 即便在Notify中发生了崩溃，也会使用defer再次继续循环
 		defer loop.rawPost()
 	}
 
+	if !loop.lastTick.IsZero() {
+		loop.Delta = now.Sub(loop.lastTick)
+	}
+
+	loop.lastTick = now
+
 	loop.Notify()
 }
 
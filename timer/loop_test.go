@@ -10,6 +10,10 @@ import (
 
 func TestLoopPanic(t *testing.T) {
 
+	clock := NewFakeClock(time.Now())
+	SetClock(clock)
+	defer SetClock(nil)
+
 	q := cellnet.NewEventQueue()
 	q.EnableCapturePanic(true)
 
@@ -27,9 +31,14 @@ func TestLoopPanic(t *testing.T) {
 
 		fmt.Println("before")
 		panic("panic")
-		fmt.Println("after")
 
 	}, nil).Start()
 
+	for i := 0; i < 3; i++ {
+		// 等待上一次回调崩溃后, 继续循环的定时器
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond * 100)
+	}
+
 	q.Wait()
 }
@@ -0,0 +1,239 @@
+package timer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luis-quan/cellnet"
+)
+
+// 错过触发时间后(进程暂停, 系统休眠, 回调阻塞队列等)的补偿策略
+type CatchUpPolicy int
+
+const (
+	CatchUp_Once CatchUpPolicy = iota // 错过的多次触发合并为一次回调, 通过Missed获取错过次数
+	CatchUp_All                       // 错过的每次触发都补回调一次
+)
+
+// 单次醒来检查的最长等待时间
+// 系统休眠期间单调时钟可能不前进, 定期用墙上时间校验, 避免休眠后长时间不触发
+const maxScheduleWait = time.Minute
+
+// 单次补偿的最大次数, 避免暂停过久时回调风暴
+const maxCatchUpTimes = 1000
+
+// 按日程规则(cron表达式, 每天/每周固定时间等)触发的定时器
+type Schedule struct {
+	Context interface{}
+
+	// 本次回调对应的计划触发时间
+	Planned time.Time
+
+	// 本次回调前因暂停等原因错过的触发次数(CatchUp_All时为剩余待补偿的次数)
+	Missed int
+
+	Queue cellnet.EventQueue
+
+	rule           ScheduleRule
+	loc            *time.Location
+	policy         CatchUpPolicy
+	notifyCallback func(*Schedule)
+
+	running int64
+
+	// 每次Start递增, 丢弃Stop前已经投递的回调
+	generation int64
+
+	next       time.Time
+	timer      AfterStopper
+	timerGuard sync.Mutex
+}
+
+func (self *Schedule) Running() bool {
+	return atomic.LoadInt64(&self.running) != 0
+}
+
+// 设置计算日程使用的时区, 默认为time.Local
+func (self *Schedule) SetLocation(loc *time.Location) *Schedule {
+	self.loc = loc
+	return self
+}
+
+func (self *Schedule) Location() *time.Location {
+	return self.loc
+}
+
+// 设置错过触发后的补偿策略, 默认为CatchUp_Once
+func (self *Schedule) SetCatchUpPolicy(policy CatchUpPolicy) *Schedule {
+	self.policy = policy
+	return self
+}
+
+// 下一次计划触发时间, 未开始或规则无下一次时为零值
+func (self *Schedule) Next() time.Time {
+	self.timerGuard.Lock()
+	defer self.timerGuard.Unlock()
+	return self.next
+}
+
+// 开始日程
+func (self *Schedule) Start() bool {
+
+	if !atomic.CompareAndSwapInt64(&self.running, 0, 1) {
+		return false
+	}
+
+	next := self.rule.Next(wallNow().In(self.loc))
+
+	self.timerGuard.Lock()
+	self.next = next
+	self.generation++
+	self.timerGuard.Unlock()
+
+	self.rawPost()
+
+	return true
+}
+
+// 停止日程, 已经投递到队列的回调仍然会被执行
+func (self *Schedule) Stop() {
+
+	atomic.StoreInt64(&self.running, 0)
+
+	self.timerGuard.Lock()
+	if self.timer != nil {
+		self.timer.Stop()
+		self.timer = nil
+	}
+	self.timerGuard.Unlock()
+}
+
+func (self *Schedule) SetNotifyFunc(notifyCallback func(*Schedule)) *Schedule {
+	self.notifyCallback = notifyCallback
+	return self
+}
+
+func (self *Schedule) NotifyFunc() func(*Schedule) {
+	return self.notifyCallback
+}
+
+func (self *Schedule) rawPost() {
+
+	if !self.Running() {
+		return
+	}
+
+	self.timerGuard.Lock()
+	defer self.timerGuard.Unlock()
+
+	// 规则已经没有下一次
+	if self.next.IsZero() {
+		return
+	}
+
+	wait := self.next.Sub(wallNow())
+	if wait > maxScheduleWait {
+		wait = maxScheduleWait
+	} else if wait < 0 {
+		wait = 0
+	}
+
+	generation := self.generation
+
+	self.timer = After(self.Queue, wait, func() {
+		self.onTimer(generation)
+	}, nil)
+}
+
+func (self *Schedule) onTimer(generation int64) {
+
+	if !self.Running() {
+		return
+	}
+
+	now := wallNow()
+
+	self.timerGuard.Lock()
+	planned := self.next
+	stale := generation != self.generation
+	self.timerGuard.Unlock()
+
+	if stale {
+		return
+	}
+
+	// 提前醒来(最长等待或时钟调整), 继续等待
+	if now.Before(planned) {
+		self.rawPost()
+		return
+	}
+
+	// 收集所有已经过期的计划时间
+	var plannedList []time.Time
+	next := planned
+	for !next.IsZero() && !next.After(now) && len(plannedList) < maxCatchUpTimes {
+		plannedList = append(plannedList, next)
+		next = self.rule.Next(next.In(self.loc))
+	}
+
+	// 过期太多时, 从当前时间重新计算下一次
+	if len(plannedList) >= maxCatchUpTimes {
+		next = self.rule.Next(now.In(self.loc))
+	}
+
+	self.timerGuard.Lock()
+	self.next = next
+	self.timerGuard.Unlock()
+
+	// 即便在回调中发生了崩溃, 也会使用defer继续日程
+	defer self.rawPost()
+
+	switch self.policy {
+	case CatchUp_All:
+		for i, t := range plannedList {
+
+			if !self.Running() {
+				break
+			}
+
+			self.Planned = t
+			self.Missed = len(plannedList) - i - 1
+			self.notifyCallback(self)
+		}
+	default:
+		self.Planned = plannedList[len(plannedList)-1]
+		self.Missed = len(plannedList) - 1
+		self.notifyCallback(self)
+	}
+}
+
+// 去掉单调时钟读数, 保证暂停或休眠后按墙上时间比较
+func wallNow() time.Time {
+	return Now().Round(0)
+}
+
+// 按日程规则持续触发callback
+// q: 队列,在指定的队列goroutine执行, 空时,直接在定时器goroutine执行
+// context: 将context上下文传递到带有context指针的函数回调中
+func NewSchedule(q cellnet.EventQueue, rule ScheduleRule, notifyCallback func(*Schedule), context interface{}) *Schedule {
+
+	return &Schedule{
+		Context:        context,
+		Queue:          q,
+		rule:           rule,
+		loc:            time.Local,
+		notifyCallback: notifyCallback,
+	}
+}
+
+// 使用cron表达式创建日程, 表达式格式见ParseCron
+func NewCronSchedule(q cellnet.EventQueue, expr string, notifyCallback func(*Schedule), context interface{}) (*Schedule, error) {
+
+	rule, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSchedule(q, rule, notifyCallback, context), nil
+}
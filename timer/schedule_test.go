@@ -0,0 +1,74 @@
+package timer
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc
+}
+
+func expectNext(t *testing.T, rule ScheduleRule, from, expect time.Time) {
+
+	next := rule.Next(from)
+	if !next.Equal(expect) {
+		t.Errorf("next of %s: expect %s, got %s", from, expect, next)
+	}
+}
+
+func TestParseCron(t *testing.T) {
+
+	loc := time.UTC
+
+	// 每周一05:00
+	rule := MustParseCron("0 5 * * mon")
+	expectNext(t, rule, time.Date(2026, 10, 19, 4, 0, 0, 0, loc), time.Date(2026, 10, 19, 5, 0, 0, 0, loc))
+	expectNext(t, rule, time.Date(2026, 10, 19, 5, 0, 0, 0, loc), time.Date(2026, 10, 26, 5, 0, 0, 0, loc))
+
+	// 每15分钟
+	rule = MustParseCron("*/15 * * * *")
+	expectNext(t, rule, time.Date(2026, 10, 19, 4, 16, 30, 0, loc), time.Date(2026, 10, 19, 4, 30, 0, 0, loc))
+
+	// 6段, 带秒
+	rule = MustParseCron("30 0 12 1,15 * *")
+	expectNext(t, rule, time.Date(2026, 10, 2, 0, 0, 0, 0, loc), time.Date(2026, 10, 15, 12, 0, 30, 0, loc))
+
+	// 描述符
+	rule = MustParseCron("@daily")
+	expectNext(t, rule, time.Date(2026, 12, 31, 23, 0, 0, 0, loc), time.Date(2027, 1, 1, 0, 0, 0, 0, loc))
+
+	for _, expr := range []string{"", "* * *", "60 * * * *", "* * 32 * *", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expect error: '%s'", expr)
+		}
+	}
+}
+
+func TestScheduleRuleDST(t *testing.T) {
+
+	loc := mustLoadLocation(t, "America/New_York")
+
+	// 2026-03-08 02:30 不存在, 跳过当天
+	rule := Daily(2, 30, 0)
+	expectNext(t, rule, time.Date(2026, 3, 7, 12, 0, 0, 0, loc), time.Date(2026, 3, 9, 2, 30, 0, 0, loc))
+
+	// 2026-11-01 01:30 出现两次, 只触发第一次
+	rule = Daily(1, 30, 0)
+	first := rule.Next(time.Date(2026, 10, 31, 12, 0, 0, 0, loc))
+	if _, offset := first.Zone(); offset != -4*3600 {
+		t.Errorf("expect first occurrence in EDT, got %s", first)
+	}
+
+	expectNext(t, rule, first, time.Date(2026, 11, 2, 1, 30, 0, 0, loc))
+
+	// 每周一05:00, 跨越夏令时结束仍然按本地时间触发
+	rule = Weekly(time.Monday, 5, 0, 0)
+	expectNext(t, rule, time.Date(2026, 10, 27, 0, 0, 0, 0, loc), time.Date(2026, 11, 2, 5, 0, 0, 0, loc))
+}
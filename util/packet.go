@@ -3,6 +3,7 @@ package util
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/luis-quan/cellnet"
@@ -13,8 +14,22 @@ var (
 	ErrMaxPacket  = errors.New("packet over size")
 	ErrMinPacket  = errors.New("packet short size")
 	ErrShortMsgID = errors.New("short msgid")
+
+	ErrUnknownMsgID = errors.New("msgid not registered")
+	ErrPacketSize   = errors.New("packet size mismatch")
 )
 
+// 消息解码失败, 记录消息ID及消息体长度
+type DecodeError struct {
+	MsgID int
+	Size  int
+	Err   error
+}
+
+func (self *DecodeError) Error() string {
+	return fmt.Sprintf("decode message failed, msgid: %d, size: %d, %s", self.MsgID, self.Size, self.Err)
+}
+
 const (
 	bodySize  = 2 // 包体大小字段
 	msgIDSize = 2 // 消息ID字段
@@ -57,7 +72,7 @@ func RecvLTVPacket(reader io.Reader, maxPacketSize int) (msg interface{}, id int
 	}
 
 	if len(body) < msgIDSize {
-		return nil, 0, ErrShortMsgID
+		return nil, 0, &DecodeError{Size: len(body), Err: ErrShortMsgID}
 	}
 
 	msgid := binary.LittleEndian.Uint16(body)
@@ -67,8 +82,8 @@ func RecvLTVPacket(reader io.Reader, maxPacketSize int) (msg interface{}, id int
 	// 将字节数组和消息ID用户解出消息
 	msg, _, err = codec.DecodeMessage(int(msgid), msgData)
 	if err != nil {
-		// TODO 接收错误时，返回消息
-		return nil, 0, err
+		// 封包已经完整读取, 按Peer的解码错误策略决定是否断开
+		return nil, 0, &DecodeError{MsgID: id, Size: len(msgData), Err: err}
 	}
 
 	return